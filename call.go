@@ -0,0 +1,248 @@
+package churn
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/rydrman/churn/churncore"
+
+	"github.com/satori/go.uuid"
+
+	"github.com/pkg/errors"
+)
+
+// pendingCall collects the outputs of a single call
+type pendingCall struct {
+	results map[string]interface{}
+	done    chan struct{}
+}
+
+// graphOutput is an out port that is observed for the outputs of calls
+type graphOutput struct {
+	portPath string
+	cancel   func()
+}
+
+// AddInput designates an in port as a named input of the graph,
+// which can be given a value in each Call
+func (g *Graph) AddInput(name, portPath string) error {
+
+	if g.GetInPort(portPath) == nil {
+		return errors.Wrap(ErrPortNotExist, portPath)
+	}
+
+	g.callLock.Lock()
+	defer g.callLock.Unlock()
+	if _, exists := g.inputs[name]; exists {
+		return errors.Wrap(ErrNameTaken, name)
+	}
+	if g.inputs == nil {
+		g.inputs = make(map[string]string)
+	}
+	g.inputs[name] = portPath
+	return nil
+
+}
+
+// AddOutput designates an out port as a named output of the graph,
+// whose value is returned from each Call until it is removed
+func (g *Graph) AddOutput(name, portPath string) error {
+
+	g.callLock.Lock()
+	defer g.callLock.Unlock()
+	if _, exists := g.outputs[name]; exists {
+		return errors.Wrap(ErrNameTaken, name)
+	}
+
+	cancel, err := g.observe(portPath, func(env *Envelope, val reflect.Value) {
+		g.collect(name, env.CorrelationID, val.Interface())
+	})
+	if err != nil {
+		return err
+	}
+	if g.outputs == nil {
+		g.outputs = make(map[string]*graphOutput)
+	}
+	g.outputs[name] = &graphOutput{portPath: portPath, cancel: cancel}
+	return nil
+
+}
+
+// RemoveOutput stops observing the named graph output, which is no
+// longer returned from any Call, including those already waiting
+func (g *Graph) RemoveOutput(name string) error {
+
+	g.callLock.Lock()
+	output, exists := g.outputs[name]
+	delete(g.outputs, name)
+	g.callLock.Unlock()
+	if !exists {
+		return errors.Wrapf(ErrPortNotExist, "output %s", name)
+	}
+	output.cancel()
+
+	// calls may now have all of the remaining outputs
+	g.callLock.Lock()
+	defer g.callLock.Unlock()
+	for _, call := range g.calls {
+		delete(call.results, name)
+		call.complete(len(g.outputs))
+	}
+	return nil
+
+}
+
+// removeOutputs stops observing all graph outputs
+func (g *Graph) removeOutputs() {
+
+	g.callLock.Lock()
+	outputs := g.outputs
+	g.outputs = nil
+	g.callLock.Unlock()
+	for _, output := range outputs {
+		output.cancel()
+	}
+
+}
+
+// Call runs the graph like a function, sending each of the given
+// values to the named graph input and returning the first value from
+// each graph output that was derived from them. All the messages of
+// a call share a new correlation id, by which the outputs are matched
+// up, so many calls can be made at once. If 'ctx' is done first, its
+// error is returned along with any outputs that had already arrived,
+// and any join arguments held for the call are discarded. Deterministic
+// graphs are settled before waiting for the outputs. A graph without
+// any outputs cannot be called
+func (g *Graph) Call(ctx context.Context, inputs map[string]interface{}) (map[string]interface{}, error) {
+
+	g.callLock.Lock()
+	if len(g.outputs) == 0 {
+		g.callLock.Unlock()
+		return nil, ErrNoOutputs
+	}
+	ports := make(map[string]string, len(inputs))
+	for name := range inputs {
+		portPath, ok := g.inputs[name]
+		if !ok {
+			g.callLock.Unlock()
+			return nil, errors.Wrapf(ErrPortNotExist, "input %s", name)
+		}
+		ports[name] = portPath
+	}
+	id := uuid.NewV4().String()
+	call := &pendingCall{
+		results: make(map[string]interface{}),
+		done:    make(chan struct{}),
+	}
+	if g.calls == nil {
+		g.calls = make(map[string]*pendingCall)
+	}
+	g.calls[id] = call
+	g.callLock.Unlock()
+
+	defer func() {
+		g.callLock.Lock()
+		delete(g.calls, id)
+		g.callLock.Unlock()
+	}()
+
+	sendCtx := WithCorrelationID(ctx, id)
+	for name, value := range inputs {
+		if err := g.Send(sendCtx, ports[name], value); err != nil {
+			g.forget(id)
+			return nil, errors.Wrapf(err, "input %s", name)
+		}
+	}
+	// deterministic graphs only move when stepped
+	g.Settle()
+
+	var err error
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		g.forget(id)
+	}
+
+	g.callLock.Lock()
+	defer g.callLock.Unlock()
+	results := make(map[string]interface{}, len(call.results))
+	for name, value := range call.results {
+		results[name] = value
+	}
+	return results, err
+
+}
+
+// collect records the value of a graph output for the
+// call that it is correlated with, if any
+func (g *Graph) collect(output, correlationID string, value interface{}) {
+
+	g.callLock.Lock()
+	defer g.callLock.Unlock()
+
+	call, ok := g.calls[correlationID]
+	if !ok {
+		return
+	}
+	if _, ok := g.outputs[output]; !ok {
+		// the output was removed while this value was on its way
+		return
+	}
+	if _, exists := call.results[output]; exists {
+		return
+	}
+	call.results[output] = value
+	call.complete(len(g.outputs))
+
+}
+
+// complete ends the call once it has a result for every one
+// of the graph's outputs. Must be called while holding the call lock
+func (c *pendingCall) complete(outputs int) {
+
+	select {
+	case <-c.done:
+	default:
+		if len(c.results) >= outputs {
+			close(c.done)
+		}
+	}
+
+}
+
+// forget discards the join arguments held for a correlation id
+// throughout this graph and its sub-graphs, once it is known
+// that they will never be used
+func (g *Graph) forget(correlationID string) {
+
+	g.componentMutex.Lock()
+	var (
+		nodes     []Node
+		subGraphs []*Graph
+	)
+	for _, cmpt := range g.components {
+		switch cmpt := cmpt.(type) {
+		case *Graph:
+			subGraphs = append(subGraphs, cmpt)
+		case *Replicated:
+			nodes = append(nodes, cmpt.nodes()...)
+		case Node:
+			nodes = append(nodes, cmpt)
+		}
+	}
+	g.componentMutex.Unlock()
+
+	for _, node := range nodes {
+		for _, port := range node.catalog().Ins {
+			if receiver, ok := port.core.(*churncore.Receiver); ok {
+				receiver.Forget(correlationID)
+			}
+		}
+	}
+	for _, subGraph := range subGraphs {
+		subGraph.forget(correlationID)
+	}
+
+}
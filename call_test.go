@@ -0,0 +1,161 @@
+package churn
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+type correlatedAddNode struct{ addNode }
+
+func (*correlatedAddNode) Joins() map[string]Join {
+	return map[string]Join{
+		"Sum": {Args: []string{"a", "b"}, Policy: JoinCorrelated},
+	}
+}
+
+func TestGraph_Call(t *testing.T) {
+
+	graph := NewGraph()
+	graph.Add("Add", new(correlatedAddNode))
+	graph.Add("Double", new(doubleNode))
+	defer graph.Close()
+
+	if err := graph.AddInput("a", "Add.Sum:a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := graph.AddInput("b", "Add.Sum:b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := graph.AddInput("x", "Double.Value"); err != nil {
+		t.Fatal(err)
+	}
+	graph.AddOutput("sum", "Add.SumResult")
+	graph.AddOutput("doubled", "Double.ValueResult")
+
+	// many concurrent calls must each get their own results
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			outputs, err := graph.Call(ctx, map[string]interface{}{
+				"a": float64(i), "b": float64(i), "x": int64(i),
+			})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			expected := fmt.Sprint(map[string]interface{}{
+				"sum": float64(2 * i), "doubled": int64(2 * i),
+			})
+			if actual := fmt.Sprint(outputs); actual != expected {
+				t.Errorf("expected %s, got %s", expected, actual)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	_, err := graph.Call(context.Background(), map[string]interface{}{"y": 1})
+	if !IsPortNotExist(err) {
+		t.Errorf("expected unknown input error, got %v", err)
+	}
+
+}
+
+type chanDoubleNode struct {
+	BaseNode
+	OutDoubled chan int64
+}
+
+func (n *chanDoubleNode) InValue(v int64) { n.OutDoubled <- v * 2 }
+
+func TestGraph_Call_ChannelPort(t *testing.T) {
+
+	graph := NewGraph()
+	graph.Add("Double", new(chanDoubleNode))
+	defer graph.Close()
+	graph.AddInput("x", "Double.Value")
+	graph.AddOutput("doubled", "Double.Doubled")
+
+	// values written to a channel keep the correlation
+	// id of the call whose message was being handled
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			outputs, err := graph.Call(ctx, map[string]interface{}{"x": int64(i)})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if outputs["doubled"] != int64(2*i) {
+				t.Errorf("expected %d, got %v", 2*i, outputs["doubled"])
+			}
+		}(i)
+	}
+	wg.Wait()
+
+}
+
+func TestGraph_Call_Timeout(t *testing.T) {
+
+	graph := NewGraph()
+	graph.Add("Add", new(addNode))
+	defer graph.Close()
+	graph.AddInput("a", "Add.Sum:a")
+	graph.AddOutput("sum", "Add.SumResult")
+
+	// the join never completes without its second argument
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	outputs, err := graph.Call(ctx, map[string]interface{}{"a": 1.0})
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected deadline error, got %v", err)
+	}
+	if len(outputs) != 0 {
+		t.Errorf("expected no outputs, got %v", outputs)
+	}
+
+}
+
+func TestGraph_Call_Outputs(t *testing.T) {
+
+	graph := NewGraph()
+	graph.Add("Double", new(doubleNode))
+	defer graph.Close()
+	graph.AddInput("x", "Double.Value")
+
+	_, err := graph.Call(context.Background(), map[string]interface{}{"x": int64(1)})
+	if !IsNoOutputs(err) {
+		t.Errorf("expected no outputs error, got %v", err)
+	}
+
+	graph.AddOutput("doubled", "Double.ValueResult")
+	graph.AddOutput("tagged", "Double.Doubled")
+	if err := graph.RemoveOutput("tagged"); err != nil {
+		t.Fatal(err)
+	}
+	if err := graph.RemoveOutput("tagged"); !IsPortNotExist(err) {
+		t.Errorf("expected missing output error, got %v", err)
+	}
+
+	// the removed output is no longer waited on
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	outputs, err := graph.Call(ctx, map[string]interface{}{"x": int64(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := fmt.Sprint(map[string]interface{}{"doubled": int64(4)}); fmt.Sprint(outputs) != expected {
+		t.Errorf("expected %s, got %v", expected, outputs)
+	}
+
+}
@@ -0,0 +1,133 @@
+package churncore
+
+import (
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	errInvalidKeyFunc    = errors.New("key func must take one argument and return one value")
+	errIncompatibleKeyFn = errors.New("incompatible key func type")
+)
+
+// Delivery decides which subscriptions receive each message
+// that is sent
+type Delivery interface {
+	// check validates that this delivery can be used
+	// by a sender of the given data type
+	check(dataType reflect.Type) error
+	// route returns the subscriptions that should
+	// receive the given message
+	route(val reflect.Value, subs []*Subscription) []*Subscription
+}
+
+type broadcast struct{}
+
+// Broadcast delivers every message to every subscription
+func Broadcast() Delivery { return broadcast{} }
+
+func (broadcast) check(reflect.Type) error { return nil }
+
+func (broadcast) route(val reflect.Value, subs []*Subscription) []*Subscription {
+	return subs
+}
+
+type roundRobin struct{ next uint64 }
+
+// RoundRobin delivers each message to a single subscription,
+// taking turns between them in the order they were created
+func RoundRobin() Delivery { return new(roundRobin) }
+
+func (*roundRobin) check(reflect.Type) error { return nil }
+
+func (d *roundRobin) route(val reflect.Value, subs []*Subscription) []*Subscription {
+
+	if len(subs) == 0 {
+		return nil
+	}
+	i := (atomic.AddUint64(&d.next, 1) - 1) % uint64(len(subs))
+	return subs[i : i+1]
+
+}
+
+type leastLoaded struct{ roundRobin }
+
+// LeastLoaded delivers each message to the single subscription
+// with the fewest queued and in-flight messages, taking turns
+// between subscriptions that are equally loaded. This is only
+// useful if subscriptions are queued, or their receivers are
+// shared with other senders
+func LeastLoaded() Delivery { return new(leastLoaded) }
+
+func (d *leastLoaded) route(val reflect.Value, subs []*Subscription) []*Subscription {
+
+	if len(subs) == 0 {
+		return nil
+	}
+	start := int(atomic.AddUint64(&d.next, 1) % uint64(len(subs)))
+	best := start
+	for i := range subs {
+		j := (start + i) % len(subs)
+		if subs[j].Load() < subs[best].Load() {
+			best = j
+		}
+	}
+	return subs[best : best+1]
+
+}
+
+type partition struct {
+	keyFunc reflect.Value
+	keyType reflect.Type
+}
+
+// Partition delivers each message to a single subscription chosen
+// by hashing the key that 'keyFunc' returns for the message, so that
+// messages with equal keys reach the same subscription for as long
+// as the set of subscriptions is unchanged. 'keyFunc' must be of
+// the form func(T) K
+func Partition(keyFunc interface{}) (Delivery, error) {
+
+	funcVal := reflect.ValueOf(keyFunc)
+	funcType := funcVal.Type()
+
+	if funcType.Kind() != reflect.Func ||
+		funcType.NumIn() != 1 || funcType.NumOut() != 1 {
+		return nil, errors.Wrapf(errInvalidKeyFunc, "invalid type %T", keyFunc)
+	}
+
+	return &partition{
+		keyFunc: funcVal,
+		keyType: funcType.In(0),
+	}, nil
+
+}
+
+func (d *partition) check(dataType reflect.Type) error {
+
+	if !dataType.AssignableTo(d.keyType) {
+		return errors.Wrapf(
+			errIncompatibleKeyFn,
+			"cannot assign [%s] -> [%s]", dataType, d.keyType,
+		)
+	}
+	return nil
+
+}
+
+func (d *partition) route(val reflect.Value, subs []*Subscription) []*Subscription {
+
+	if len(subs) == 0 {
+		return nil
+	}
+	key := d.keyFunc.Call([]reflect.Value{val})[0]
+	hash := fnv.New32a()
+	fmt.Fprint(hash, key.Interface())
+	i := hash.Sum32() % uint32(len(subs))
+	return subs[i : i+1]
+
+}
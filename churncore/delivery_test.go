@@ -0,0 +1,114 @@
+package churncore
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// countingSubs creates a sender with n subscribers that
+// each count the messages that they receive
+func countingSubs(t *testing.T, n int) (*Sender, []int) {
+
+	sender := NewDirectSender(reflect.TypeOf(0))
+	counts := make([]int, n)
+	for i := range counts {
+		i := i
+		receiver, err := NewReceiver(func(int) { counts[i]++ })
+		if err != nil {
+			t.Fatal(err)
+		}
+		sender.Subscribe(receiver)
+	}
+	return sender, counts
+
+}
+
+func TestDelivery_RoundRobin(t *testing.T) {
+
+	sender, counts := countingSubs(t, 3)
+	sender.SetDelivery(RoundRobin())
+	for i := 0; i < 6; i++ {
+		sender.send(nil, nil, reflect.ValueOf(i))
+	}
+	if !reflect.DeepEqual(counts, []int{2, 2, 2}) {
+		t.Errorf("expected messages to be evenly distributed, got %v", counts)
+	}
+
+}
+
+func TestDelivery_Partition(t *testing.T) {
+
+	_, err := Partition(func() {})
+	if errors.Cause(err) != errInvalidKeyFunc {
+		t.Errorf("expected key func without argument to give relevant error, got: %s", err)
+	}
+
+	sender, counts := countingSubs(t, 3)
+	odd, err := Partition(func(v string) bool { return len(v)%2 == 1 })
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sender.SetDelivery(odd)
+	if errors.Cause(err) != errIncompatibleKeyFn {
+		t.Errorf("expected key func of wrong type to give relevant error, got: %s", err)
+	}
+
+	even, _ := Partition(func(v int) bool { return v%2 == 0 })
+	if err = sender.SetDelivery(even); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		sender.send(nil, nil, reflect.ValueOf(2))
+	}
+	total, used := 0, 0
+	for _, c := range counts {
+		total += c
+		if c > 0 {
+			used++
+		}
+	}
+	if total != 10 || used != 1 {
+		t.Errorf("expected all messages with the same key in one subscription, got %v", counts)
+	}
+
+}
+
+func TestDelivery_LeastLoaded(t *testing.T) {
+
+	sender := NewDirectSender(reflect.TypeOf(0))
+	sender.SetDelivery(LeastLoaded())
+
+	block := make(chan struct{})
+	busy, _ := NewReceiver(func(int) { <-block })
+	idleCalls := make(chan int, 10)
+	idle, _ := NewReceiver(func(v int) { idleCalls <- v })
+
+	busySub, _ := sender.SubscribeWith(busy, SubscribeOptions{QueueSize: 10})
+	idleSub, _ := sender.SubscribeWith(idle, SubscribeOptions{QueueSize: 10})
+	defer busySub.Close()
+	defer idleSub.Close()
+
+	// equally loaded subscriptions take turns, which
+	// leaves the busy subscription blocked on one message
+	sender.send(nil, nil, reflect.ValueOf(0))
+	sender.send(nil, nil, reflect.ValueOf(0))
+	<-idleCalls
+	waitForLoad(busySub, 1)
+
+	for i := 1; i <= 3; i++ {
+		waitForLoad(idleSub, 0)
+		sender.send(nil, nil, reflect.ValueOf(i))
+		<-idleCalls
+	}
+	close(block)
+
+}
+
+func waitForLoad(s *Subscription, load int) {
+	for s.Load() != load {
+		runtime.Gosched()
+	}
+}
@@ -0,0 +1,177 @@
+package churncore
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type envelopeKey struct{}
+type lineageKey struct{}
+
+// MaxAncestry is the most ancestors that an envelope keeps. When
+// a chain of derived messages grows past it, the older half of the
+// chain is dropped so that a long running pipeline does not keep
+// every message that its latest message was derived from
+const MaxAncestry = 64
+
+// Envelope carries metadata alongside a message as
+// it moves from a sender to its receivers
+type Envelope struct {
+	// Time is when the message was sent
+	Time time.Time
+	// Source is the path of the port that sent the message
+	Source string
+	// Sequence increases by one with each message sent
+	// by the same sender, starting at one
+	Sequence uint64
+	// CorrelationID identifies the original message
+	// that caused a receiver to be called, and is shared
+	// by all messages derived from it
+	CorrelationID string
+	// Headers holds arbitrary user metadata, which is
+	// copied into any messages derived from this one
+	Headers map[string]string
+	// Parent is the envelope of the message that this
+	// message was derived from, if any. Only the most
+	// recent MaxAncestry ancestors are kept
+	Parent *Envelope
+
+	// depth is the number of ancestors that are kept
+	depth int
+}
+
+// Header returns the value of the named header, or an
+// empty string if the header is not set
+func (e *Envelope) Header(name string) string {
+	return e.Headers[name]
+}
+
+// trimAncestry returns 'env', or a copy of it if needed, which
+// keeps at most 'keep' envelopes including itself
+func trimAncestry(env *Envelope, keep int) *Envelope {
+
+	if env == nil || env.depth < keep {
+		return env
+	}
+	trimmed := *env
+	trimmed.depth = keep - 1
+	trimmed.Parent = nil
+	if keep > 1 {
+		trimmed.Parent = trimAncestry(env.Parent, keep-1)
+	}
+	return &trimmed
+
+}
+
+// Lineage relates the messages sent by a single node to
+// the message that the node most recently received. Values written
+// to channels during a call are related to the message of that call,
+// as long as the calls of the node's handlers do not overlap
+type Lineage struct {
+	mutex   sync.Mutex
+	parent  *Envelope
+	headers map[string]string
+
+	// senders read the channels that are written to
+	// during calls, and are synced as each call ends
+	senders []*Sender
+}
+
+// SetHeader sets a header on all messages that are sent
+// until the next message is received
+func (l *Lineage) SetHeader(name, value string) {
+
+	l.mutex.Lock()
+	if l.headers == nil {
+		l.headers = make(map[string]string)
+	}
+	l.headers[name] = value
+	l.mutex.Unlock()
+
+}
+
+// receive records that a message has been received
+func (l *Lineage) receive(env *Envelope) {
+
+	l.mutex.Lock()
+	l.parent = env
+	l.headers = copyHeaders(env.Headers)
+	l.mutex.Unlock()
+
+}
+
+// track adds a channel sender whose values are related
+// to messages by this lineage
+func (l *Lineage) track(s *Sender) {
+
+	l.mutex.Lock()
+	l.senders = append(l.senders, s)
+	l.mutex.Unlock()
+
+}
+
+// sync waits until every value written to the channels of the tracked
+// senders has been related to its parent, so that the next message
+// received cannot be mistaken for the parent of earlier values
+func (l *Lineage) sync() {
+
+	l.mutex.Lock()
+	senders := l.senders
+	l.mutex.Unlock()
+	for _, s := range senders {
+		s.sync()
+	}
+
+}
+
+// derive returns the parent and headers of the next message sent
+func (l *Lineage) derive() (*Envelope, map[string]string) {
+
+	if l == nil {
+		return nil, nil
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.parent, copyHeaders(l.headers)
+
+}
+
+func copyHeaders(headers map[string]string) map[string]string {
+
+	if len(headers) == 0 {
+		return nil
+	}
+	copied := make(map[string]string, len(headers))
+	for k, v := range headers {
+		copied[k] = v
+	}
+	return copied
+
+}
+
+// ContextWithEnvelope returns a copy of 'ctx' which carries
+// the given message envelope
+func ContextWithEnvelope(ctx context.Context, env *Envelope) context.Context {
+	return context.WithValue(ctx, envelopeKey{}, env)
+}
+
+// EnvelopeFromContext returns the message envelope carried by
+// 'ctx', or nil if there is none
+func EnvelopeFromContext(ctx context.Context) *Envelope {
+	env, _ := ctx.Value(envelopeKey{}).(*Envelope)
+	return env
+}
+
+// ContextWithLineage returns a copy of 'ctx' which carries
+// the given lineage
+func ContextWithLineage(ctx context.Context, lineage *Lineage) context.Context {
+	return context.WithValue(ctx, lineageKey{}, lineage)
+}
+
+// LineageFromContext returns the lineage carried by
+// 'ctx', or nil if there is none
+func LineageFromContext(ctx context.Context) *Lineage {
+	lineage, _ := ctx.Value(lineageKey{}).(*Lineage)
+	return lineage
+}
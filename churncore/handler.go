@@ -0,0 +1,354 @@
+package churncore
+
+import (
+	"context"
+	"reflect"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// handler calls a message handling function on behalf of one
+// or more receivers, and manages its context, errors and results
+type handler struct {
+	function    reflect.Value
+	withContext bool
+
+	// resultType is set for functions that return a value,
+	// and all such values are forwarded to the result sender
+	resultType reflect.Type
+	result     *Sender
+
+	ctx     context.Context
+	timeout time.Duration
+	onError func(error)
+	lineage *Lineage
+
+	// busy tracks calls to the handler function
+	busy    stallClock
+	metrics handlerMetrics
+
+	// tracer provides the function that records
+	// the span of each call, if it is traced
+	tracer func() func(*Span)
+
+	// resume is set while the handler is paused, and
+	// closed when it is resumed
+	resume    chan struct{}
+	pauseLock sync.Mutex
+
+	// running counts the calls in progress, and idle is
+	// set while the handler is closing and closed once
+	// the last of them returns
+	running int
+	closed  bool
+	idle    chan struct{}
+	runLock sync.Mutex
+}
+
+// newHandler validates the given handler function, returning the
+// types of the message parameters that it takes
+func newHandler(handlerFunc interface{}) (*handler, []reflect.Type, error) {
+
+	funcVal := reflect.ValueOf(handlerFunc)
+	funcType := funcVal.Type()
+
+	if funcType.Kind() != reflect.Func {
+		return nil, nil, errors.Wrapf(errNotAFunction, "invalid type %T", handlerFunc)
+	}
+
+	var argTypes []reflect.Type
+	for i := 0; i < funcType.NumIn(); i++ {
+		argTypes = append(argTypes, funcType.In(i))
+	}
+
+	withContext := len(argTypes) > 0 && argTypes[0] == contextType
+	if withContext {
+		argTypes = argTypes[1:]
+	}
+
+	var resultType reflect.Type
+	switch funcType.NumOut() {
+	case 0:
+	case 1:
+		if funcType.Out(0) != errorType {
+			return nil, nil, errWrongNumberOfReturns
+		}
+	case 2:
+		// an error result would be ambiguous with the error itself
+		if funcType.Out(0) == errorType || funcType.Out(1) != errorType {
+			return nil, nil, errWrongNumberOfReturns
+		}
+		resultType = funcType.Out(0)
+	default:
+		return nil, nil, errWrongNumberOfReturns
+	}
+
+	return &handler{
+		function:    funcVal,
+		withContext: withContext,
+		resultType:  resultType,
+	}, argTypes, nil
+
+}
+
+// ResultType returns the type of value returned by the handler
+// function, or nil if the function does not return a result
+func (h *handler) ResultType() reflect.Type {
+	return h.resultType
+}
+
+// SetResultSender sets the sender that all results from the
+// handler function are forwarded to
+func (h *handler) SetResultSender(s *Sender) error {
+
+	if h.resultType == nil || !h.resultType.AssignableTo(s.dataType) {
+		return errors.Wrapf(
+			errIncompatibleResult,
+			"cannot assign [%v] -> [%s]", h.resultType, s.dataType,
+		)
+	}
+	h.result = s
+	return nil
+
+}
+
+// SetContext sets the parent context of all contexts given to
+// the handler function. Cancelling 'ctx' cancels all in-flight
+// and future calls to the function
+func (h *handler) SetContext(ctx context.Context) {
+	h.ctx = ctx
+}
+
+// SetTimeout sets the deadline applied to the context of each
+// individual call to the handler function, or none if zero
+func (h *handler) SetTimeout(timeout time.Duration) {
+	h.timeout = timeout
+}
+
+// SetErrorHandler sets a function to be called with any
+// non-nil error returned by the handler function
+func (h *handler) SetErrorHandler(onError func(error)) {
+	h.onError = onError
+}
+
+// SetLineage sets the lineage that records each message
+// handled, and so relates it to the messages sent afterwards
+func (h *handler) SetLineage(lineage *Lineage) {
+	h.lineage = lineage
+}
+
+// Pause holds all messages given to the handler until it is resumed,
+// which blocks their senders. When the sender is scheduled this blocks
+// the scheduler, so a deterministic graph should not be stepped while
+// any of its handlers are paused
+func (h *handler) Pause() {
+
+	h.pauseLock.Lock()
+	if h.resume == nil {
+		h.resume = make(chan struct{})
+	}
+	h.pauseLock.Unlock()
+
+}
+
+// Resume releases all messages held since the handler was paused
+func (h *handler) Resume() {
+
+	h.pauseLock.Lock()
+	if h.resume != nil {
+		close(h.resume)
+		h.resume = nil
+	}
+	h.pauseLock.Unlock()
+
+}
+
+// Paused returns true if the handler is currently paused
+func (h *handler) Paused() bool {
+
+	h.pauseLock.Lock()
+	defer h.pauseLock.Unlock()
+	return h.resume != nil
+
+}
+
+// waitResumed blocks while the handler is paused, or
+// until the handler's context is cancelled
+func (h *handler) waitResumed() {
+
+	h.pauseLock.Lock()
+	resume := h.resume
+	h.pauseLock.Unlock()
+	if resume == nil {
+		return
+	}
+
+	var done <-chan struct{}
+	if h.ctx != nil {
+		done = h.ctx.Done()
+	}
+	select {
+	case <-resume:
+	case <-done:
+	}
+
+}
+
+// Close stops the handler function from being called again and
+// waits for any calls in progress to return. Messages held while
+// the handler is paused are released and dropped
+func (h *handler) Close() {
+
+	h.runLock.Lock()
+	h.closed = true
+	var idle chan struct{}
+	if h.running > 0 {
+		if h.idle == nil {
+			h.idle = make(chan struct{})
+		}
+		idle = h.idle
+	}
+	h.runLock.Unlock()
+
+	h.Resume()
+	if idle != nil {
+		<-idle
+	}
+
+}
+
+// enter records the start of a call, returning false
+// if the handler is closed and must not be called
+func (h *handler) enter() bool {
+
+	h.runLock.Lock()
+	defer h.runLock.Unlock()
+	if h.closed {
+		return false
+	}
+	h.running++
+	return true
+
+}
+
+// exit records the end of a call started by enter
+func (h *handler) exit() {
+
+	h.runLock.Lock()
+	h.running--
+	if h.running == 0 && h.idle != nil {
+		close(h.idle)
+		h.idle = nil
+	}
+	h.runLock.Unlock()
+
+}
+
+// Stalled returns how long the handler function has been running
+// without any call returning, or zero if it is not running
+func (h *handler) Stalled() time.Duration {
+	return h.busy.stalled()
+}
+
+// invoke calls the handler function, recording its latency
+// and recovering from any panic as an error
+func (h *handler) invoke(args []reflect.Value) (out []reflect.Value, err error) {
+
+	h.busy.begin()
+	start := time.Now()
+	defer func() {
+		h.metrics.observe(time.Since(start))
+		h.busy.end()
+		if r := recover(); r != nil {
+			atomic.AddUint64(&h.metrics.panics, 1)
+			err = errors.Wrapf(ErrPanic, "%v\n%s", r, debug.Stack())
+		}
+	}()
+	return h.function.Call(args), nil
+
+}
+
+// Stats returns a snapshot of the calls made to the handler function
+func (h *handler) Stats() HandlerStats {
+	return h.metrics.snapshot()
+}
+
+// call invokes the handler function with the given message arguments
+func (h *handler) call(env *Envelope, args []reflect.Value) {
+
+	if !h.enter() {
+		return
+	}
+	defer h.exit()
+
+	lineage := h.lineage
+	if lineage == nil {
+		lineage = new(Lineage)
+	}
+	lineage.receive(env)
+
+	span, record := h.startSpan(env)
+	if span != nil {
+		lineage.SetHeader(TraceParentHeader, span.traceParent())
+		defer func() { record(span) }()
+	}
+
+	if h.withContext {
+		ctx := h.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		if h.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, h.timeout)
+			defer cancel()
+		}
+		ctx = ContextWithEnvelope(ctx, env)
+		ctx = ContextWithLineage(ctx, lineage)
+		args = append([]reflect.Value{reflect.ValueOf(ctx)}, args...)
+	}
+
+	out, err := h.invoke(args)
+	// values written to channels during the call are
+	// related to its message before another is received
+	lineage.sync()
+	if err == nil && len(out) > 0 {
+		err, _ = out[len(out)-1].Interface().(error)
+	}
+	if span != nil {
+		span.End = time.Now()
+		span.Err = err
+	}
+	if err != nil {
+		atomic.AddUint64(&h.metrics.errors, 1)
+		if h.onError != nil {
+			h.onError(err)
+		}
+		return
+	}
+	if len(out) == 0 {
+		return
+	}
+	if h.result != nil {
+		// results are always related to the message that produced
+		// them, even if the lineage has since moved on
+		_, headers := lineage.derive()
+		if span != nil {
+			if headers == nil {
+				headers = make(map[string]string)
+			}
+			headers[TraceParentHeader] = span.traceParent()
+		}
+		h.result.send(env, headers, out[0])
+	}
+
+}
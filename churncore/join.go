@@ -0,0 +1,186 @@
+package churncore
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+var errTooFewJoinArgs = errors.New("join func must take at least two arguments")
+
+// JoinPolicy decides when a join function is called,
+// and which of its argument values are consumed
+type JoinPolicy int
+
+// The available join policies
+const (
+	// JoinZip calls the function once a value has arrived for every
+	// argument, consuming the oldest value of each argument in the
+	// process such that every value is used exactly once
+	JoinZip JoinPolicy = iota
+	// JoinLatest calls the function with the latest value of each
+	// argument every time any argument receives a new value, once
+	// all arguments have received at least one value
+	JoinLatest
+	// JoinCorrelated is like JoinZip, but only calls the function with
+	// values that share a correlation id, so that the arguments of many
+	// concurrent requests are never mixed up. Values whose correlation
+	// id never receives a value for every argument are kept until
+	// that correlation id is forgotten
+	JoinCorrelated
+)
+
+// Join represents a function with multiple message arguments,
+// which is called once the arguments have values according to
+// the join policy
+type Join struct {
+	*handler
+	policy    JoinPolicy
+	receivers []*Receiver
+
+	// sticky arguments retain their latest value
+	// rather than being consumed under JoinZip
+	sticky []bool
+	latest []reflect.Value
+	queues [][]reflect.Value
+	mutex  sync.Mutex
+
+	// correlated holds the queues of each correlation id
+	// for the JoinCorrelated policy
+	correlated map[string][][]reflect.Value
+}
+
+// NewJoin creates a join from the given function. 'joinFunc' follows
+// the same rules as the functions given to NewReceiver, except that it
+// must take at least two message parameters
+func NewJoin(joinFunc interface{}, policy JoinPolicy) (*Join, error) {
+
+	h, argTypes, err := newHandler(joinFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(argTypes) < 2 {
+		return nil, errTooFewJoinArgs
+	}
+
+	j := &Join{
+		handler: h,
+		policy:  policy,
+		sticky:  make([]bool, len(argTypes)),
+		latest:  make([]reflect.Value, len(argTypes)),
+		queues:  make([][]reflect.Value, len(argTypes)),
+
+		correlated: make(map[string][][]reflect.Value),
+	}
+	for i, argType := range argTypes {
+		j.receivers = append(j.receivers, &Receiver{
+			handler:  h,
+			dataType: argType,
+			join:     j,
+			index:    i,
+		})
+	}
+	return j, nil
+
+}
+
+// Receivers returns one receiver for each argument of the join
+// function, in the order that they appear in the function signature
+func (j *Join) Receivers() []*Receiver {
+	return j.receivers
+}
+
+// SetSticky marks the argument at index 'i' as sticky. Sticky
+// arguments are never consumed by JoinZip, and their latest value
+// is instead reused until a new one arrives
+func (j *Join) SetSticky(i int, sticky bool) {
+
+	j.mutex.Lock()
+	j.sticky[i] = sticky
+	j.mutex.Unlock()
+
+}
+
+// offer provides a new value for the argument at index 'i',
+// calling the join function if all arguments are then ready
+func (j *Join) offer(i int, env *Envelope, val reflect.Value) {
+
+	j.mutex.Lock()
+	queues := j.queues
+	if j.policy == JoinCorrelated {
+		queues = j.correlated[env.CorrelationID]
+		if queues == nil {
+			queues = make([][]reflect.Value, len(j.receivers))
+			j.correlated[env.CorrelationID] = queues
+		}
+	}
+	if j.consumes(i) {
+		queues[i] = append(queues[i], val)
+	} else {
+		j.latest[i] = val
+	}
+	args := j.take(queues)
+	if j.policy == JoinCorrelated && j.isEmpty(queues) {
+		delete(j.correlated, env.CorrelationID)
+	}
+	j.mutex.Unlock()
+
+	if args != nil {
+		j.call(env, args)
+	}
+
+}
+
+// forget discards the values held for the given correlation id
+func (j *Join) forget(correlationID string) {
+
+	j.mutex.Lock()
+	delete(j.correlated, correlationID)
+	j.mutex.Unlock()
+
+}
+
+func (j *Join) consumes(i int) bool {
+	return j.policy != JoinLatest && !j.sticky[i]
+}
+
+// isEmpty returns true if none of the given queues hold a value
+func (j *Join) isEmpty(queues [][]reflect.Value) bool {
+
+	for _, queue := range queues {
+		if len(queue) > 0 {
+			return false
+		}
+	}
+	return true
+
+}
+
+// take returns the next set of arguments for the join function from
+// the given queues, or nil if they are not all available. Must be
+// called while holding the join mutex
+func (j *Join) take(queues [][]reflect.Value) []reflect.Value {
+
+	for i := range j.receivers {
+		if j.consumes(i) && len(queues[i]) == 0 {
+			return nil
+		}
+		if !j.consumes(i) && !j.latest[i].IsValid() {
+			return nil
+		}
+	}
+
+	args := make([]reflect.Value, len(j.receivers))
+	for i := range j.receivers {
+		if j.consumes(i) {
+			args[i] = queues[i][0]
+			queues[i] = queues[i][1:]
+		} else {
+			args[i] = j.latest[i]
+		}
+	}
+	return args
+
+}
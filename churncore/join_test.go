@@ -0,0 +1,117 @@
+package churncore
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestNewJoin(t *testing.T) {
+
+	_, err := NewJoin(func(int) {}, JoinZip)
+	if errors.Cause(err) != errTooFewJoinArgs {
+		t.Errorf("expected function with one argument to give relevant error, got: %s", err)
+	}
+
+	j, err := NewJoin(func(int, string) {}, JoinZip)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	receivers := j.Receivers()
+	if len(receivers) != 2 {
+		t.Fatalf("expected one receiver per argument, got %d", len(receivers))
+	}
+	if receivers[1].dataType != reflect.TypeOf("") {
+		t.Errorf("expected receiver data type to match argument, got %s", receivers[1].dataType)
+	}
+
+}
+
+func TestJoin_Policies(t *testing.T) {
+
+	cases := []struct {
+		desc     string
+		policy   JoinPolicy
+		sticky   bool
+		expected []int
+	}{
+		{
+			desc:     "zip",
+			policy:   JoinZip,
+			expected: []int{11, 13},
+		},
+		{
+			desc:     "latest",
+			policy:   JoinLatest,
+			expected: []int{11, 12, 13, 14},
+		},
+		{
+			desc:     "sticky",
+			policy:   JoinZip,
+			sticky:   true,
+			expected: []int{11, 12},
+		},
+	}
+
+	for _, c := range cases {
+
+		var actual []int
+		j, err := NewJoin(func(a, b int) { actual = append(actual, a+b) }, c.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		j.SetSticky(0, c.sticky)
+
+		a, b := j.Receivers()[0], j.Receivers()[1]
+		env := &Envelope{}
+		a.receive(env, reflect.ValueOf(1))
+		b.receive(env, reflect.ValueOf(10))
+		b.receive(env, reflect.ValueOf(11))
+		a.receive(env, reflect.ValueOf(2))
+		a.receive(env, reflect.ValueOf(3))
+
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%s: expected calls %v, got %v", c.desc, c.expected, actual)
+		}
+
+	}
+
+}
+
+func TestJoin_Correlated(t *testing.T) {
+
+	var actual []int
+	j, err := NewJoin(func(a, b int) { actual = append(actual, a+b) }, JoinCorrelated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, b := j.Receivers()[0], j.Receivers()[1]
+	first := &Envelope{CorrelationID: "first"}
+	second := &Envelope{CorrelationID: "second"}
+	a.receive(first, reflect.ValueOf(1))
+	a.receive(second, reflect.ValueOf(2))
+	b.receive(second, reflect.ValueOf(20))
+	b.receive(first, reflect.ValueOf(10))
+
+	if expected := []int{22, 11}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected calls %v, got %v", expected, actual)
+	}
+	if len(j.correlated) != 0 {
+		t.Errorf("expected completed correlation ids to be forgotten, got %v", j.correlated)
+	}
+
+	// a partial set of arguments is kept until forgotten
+	a.receive(first, reflect.ValueOf(3))
+	b.Forget(first.CorrelationID)
+	if len(j.correlated) != 0 {
+		t.Errorf("expected forgotten correlation ids to be discarded, got %v", j.correlated)
+	}
+	b.receive(first, reflect.ValueOf(30))
+	if len(actual) != 2 {
+		t.Errorf("expected no call with forgotten arguments, got %v", actual)
+	}
+
+}
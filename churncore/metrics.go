@@ -0,0 +1,88 @@
+package churncore
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrPanic is the cause of errors reported for handler
+// functions that panic
+var ErrPanic = errors.New("handler panicked")
+
+// latencyBuckets are the upper bounds of the buckets
+// that handler latencies are counted in
+var latencyBuckets = [...]time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Histogram is a snapshot of a distribution of durations
+type Histogram struct {
+	// Buckets are the upper bounds of each bucket
+	Buckets []time.Duration
+	// Counts holds the number of durations in each bucket, with
+	// one extra count at the end for those larger than every bucket
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+}
+
+// HandlerStats is a snapshot of the activity of a handler function
+type HandlerStats struct {
+	Calls   uint64
+	Errors  uint64
+	Panics  uint64
+	Latency Histogram
+}
+
+// handlerMetrics counts the activity of a handler function
+type handlerMetrics struct {
+	calls   uint64
+	errors  uint64
+	panics  uint64
+	buckets [len(latencyBuckets) + 1]uint64
+	sum     int64
+}
+
+func (m *handlerMetrics) observe(latency time.Duration) {
+
+	atomic.AddUint64(&m.calls, 1)
+	atomic.AddInt64(&m.sum, int64(latency))
+	i := 0
+	for i < len(latencyBuckets) && latency > latencyBuckets[i] {
+		i++
+	}
+	atomic.AddUint64(&m.buckets[i], 1)
+
+}
+
+func (m *handlerMetrics) snapshot() HandlerStats {
+
+	stats := HandlerStats{
+		Calls:  atomic.LoadUint64(&m.calls),
+		Errors: atomic.LoadUint64(&m.errors),
+		Panics: atomic.LoadUint64(&m.panics),
+		Latency: Histogram{
+			Buckets: latencyBuckets[:],
+			Counts:  make([]uint64, len(m.buckets)),
+			Sum:     time.Duration(atomic.LoadInt64(&m.sum)),
+		},
+	}
+	for i := range stats.Latency.Counts {
+		stats.Latency.Counts[i] = atomic.LoadUint64(&m.buckets[i])
+		stats.Latency.Count += stats.Latency.Counts[i]
+	}
+	return stats
+
+}
@@ -2,46 +2,226 @@ package churncore
 
 import (
 	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
 var (
 	errNotAFunction         = errors.New("receiver must be a function")
-	errWrongNumberOfArgs    = errors.New("receiver func must take exactly one argument")
-	errWrongNumberOfReturns = errors.New("receiver func may only return a single error value")
+	errWrongNumberOfArgs    = errors.New("receiver func must take exactly one argument, optionally preceded by a context")
+	errWrongNumberOfReturns = errors.New("receiver func may only return an error, optionally preceded by a result value")
+	errIncompatibleResult   = errors.New("incompatible result sender type")
+	errRecvOnly             = errors.New("cannot be a receive-only channel")
+	errReorderSubscribed    = errors.New("cannot reorder a receiver that is already subscribed")
+	errReorderDelivery      = errors.New("reordered receivers can only subscribe to broadcast senders")
 )
 
 // Receiver represents a function that can handle messages of
 // a specific go data type
 type Receiver struct {
-	function reflect.Value
+	*handler
 	dataType reflect.Type
+
+	// join is set for receivers that provide a single
+	// argument to a multi-argument join function
+	join  *Join
+	index int
+
+	// relay is set for receivers that pass messages
+	// and their envelopes on to other code
+	relay func(*Envelope, reflect.Value)
+
+	// reorder is set for receivers that restore the
+	// sequence order of messages before handling them,
+	// and can only be set while no subscription is open
+	reorder    *Reorder
+	subscribed int32
+
+	received uint64
+
+	// observers are given every message received
+	observers   []*func(*Envelope, reflect.Value)
+	observeLock sync.Mutex
 }
 
 // NewReceiver creates a message receiver from the given function.
 // 'handlerFunc' must take a single parameter of the desired message
-// data type and may return nothing, or a single error, as required
+// data type and may return nothing, or a single error, as required.
+// The message parameter may also be preceded by a context.Context, in
+// which case the function is called with the receiver's context. The
+// error may also be preceded by a result value, which is sent on to
+// the receiver's result sender whenever the returned error is nil
 func NewReceiver(handlerFunc interface{}) (*Receiver, error) {
 
-	funcVal := reflect.ValueOf(handlerFunc)
-	funcType := funcVal.Type()
-
-	if funcType.Kind() != reflect.Func {
-		return nil, errors.Wrapf(errNotAFunction, "invalid type %T", handlerFunc)
+	h, argTypes, err := newHandler(handlerFunc)
+	if err != nil {
+		return nil, err
 	}
 
-	if funcType.NumIn() != 1 {
+	if len(argTypes) != 1 {
 		return nil, errWrongNumberOfArgs
 	}
 
-	if funcType.NumOut() > 1 {
-		return nil, errWrongNumberOfReturns
+	return &Receiver{
+		handler:  h,
+		dataType: argTypes[0],
+	}, nil
+
+}
+
+// NewChanReceiver creates a message receiver that forwards every
+// message into the given go channel. 'channel' must be send-able,
+// and any pending send is abandoned once 'done' is closed
+func NewChanReceiver(channel interface{}, done <-chan struct{}) (*Receiver, error) {
+
+	chanVal := reflect.ValueOf(channel)
+	chanType := chanVal.Type()
+
+	if chanType.Kind() != reflect.Chan {
+		return nil, errors.Wrapf(errNotAChannel, "invalid type %T", channel)
+	}
+
+	if chanType.ChanDir() == reflect.RecvDir {
+		return nil, errRecvOnly
 	}
 
+	doneVal := reflect.ValueOf(done)
+	funcType := reflect.FuncOf([]reflect.Type{chanType.Elem()}, nil, false)
+	funcVal := reflect.MakeFunc(funcType, func(args []reflect.Value) []reflect.Value {
+		reflect.Select([]reflect.SelectCase{
+			{Dir: reflect.SelectSend, Chan: chanVal, Send: args[0]},
+			{Dir: reflect.SelectRecv, Chan: doneVal},
+		})
+		return nil
+	})
+
 	return &Receiver{
-		dataType: funcType.In(0),
-		function: funcVal,
+		handler:  &handler{function: funcVal},
+		dataType: chanType.Elem(),
 	}, nil
 
 }
+
+// NewRelayReceiver creates a message receiver that passes each
+// message and its envelope to 'relay' without any other handling
+func NewRelayReceiver(dataType reflect.Type, relay func(*Envelope, reflect.Value)) *Receiver {
+
+	return &Receiver{
+		handler:  new(handler),
+		dataType: dataType,
+		relay:    relay,
+	}
+
+}
+
+// IsRelay returns true for receivers created with NewRelayReceiver,
+// which have no handler function of their own
+func (r *Receiver) IsRelay() bool {
+	return r.relay != nil
+}
+
+// Received returns the number of messages received so far
+func (r *Receiver) Received() uint64 {
+	return atomic.LoadUint64(&r.received)
+}
+
+// DataType returns the type of message accepted by this receiver
+func (r *Receiver) DataType() reflect.Type {
+	return r.dataType
+}
+
+// Index returns the position of this receiver's argument
+// in its join function, or zero if it is not part of a join
+func (r *Receiver) Index() int {
+	return r.index
+}
+
+// Forget discards the values held by the join of this receiver for
+// the given correlation id under the JoinCorrelated policy, once
+// it is known that they will never be used. Receivers that are
+// not part of a join hold no values
+func (r *Receiver) Forget(correlationID string) {
+
+	if r.join != nil {
+		r.join.forget(correlationID)
+	}
+
+}
+
+// SetReorder makes this receiver handle the messages from each
+// source in the order of their sequence numbers, buffering at most
+// 'window' messages per source and waiting at most 'timeout' for a
+// missing message. A zero window or timeout disables that limit.
+// The receiver must get every message that its sources send after
+// it subscribes to them, so it must not be subscribed yet and can
+// only subscribe to senders with broadcast delivery
+func (r *Receiver) SetReorder(window int, timeout time.Duration) error {
+
+	if atomic.LoadInt32(&r.subscribed) > 0 {
+		return errReorderSubscribed
+	}
+	r.reorder = NewReorder(window, timeout, BySource, r.handle)
+	return nil
+
+}
+
+// Observe calls 'observe' with every message received, before it
+// is handled, until the returned cancel function is called. Messages
+// held by a paused receiver are observed once they are released
+func (r *Receiver) Observe(observe func(*Envelope, reflect.Value)) (cancel func()) {
+
+	o := &observe
+	r.observeLock.Lock()
+	r.observers = append(r.observers, o)
+	r.observeLock.Unlock()
+
+	return func() {
+		r.observeLock.Lock()
+		defer r.observeLock.Unlock()
+		for i, existing := range r.observers {
+			if existing == o {
+				// copy so that in-flight messages keep a consistent view
+				r.observers = append(r.observers[:i:i], r.observers[i+1:]...)
+				return
+			}
+		}
+	}
+
+}
+
+// receive accepts a single message, reordering it if required
+func (r *Receiver) receive(env *Envelope, val reflect.Value) {
+
+	r.waitResumed()
+	atomic.AddUint64(&r.received, 1)
+	r.observeLock.Lock()
+	observers := r.observers
+	r.observeLock.Unlock()
+	for _, observe := range observers {
+		(*observe)(env, val)
+	}
+	if r.reorder != nil {
+		r.reorder.Add(env, val)
+		return
+	}
+	r.handle(env, val)
+
+}
+
+// handle processes a single message
+func (r *Receiver) handle(env *Envelope, val reflect.Value) {
+
+	if r.relay != nil {
+		r.relay(env, val)
+		return
+	}
+	if r.join != nil {
+		r.join.offer(r.index, env, val)
+		return
+	}
+	r.call(env, []reflect.Value{val})
+
+}
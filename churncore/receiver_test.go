@@ -1,7 +1,10 @@
 package churncore
 
 import (
+	"context"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -34,4 +37,202 @@ func TestNewReceiver(t *testing.T) {
 		t.Errorf("unexpected error: %s", err)
 	}
 
+	_, err = NewReceiver(func(context.Context, int) error { return nil })
+	if err != nil {
+		t.Errorf("unexpected error for context-aware function: %s", err)
+	}
+
+	_, err = NewReceiver(func(int) int { return 0 })
+	if errors.Cause(err) != errWrongNumberOfReturns {
+		t.Errorf("expected function returning a non-error to give relevant error, got: %s", err)
+	}
+
+	receiver, err := NewReceiver(func(int) (string, error) { return "", nil })
+	if err != nil {
+		t.Errorf("unexpected error for function with result: %s", err)
+	} else if receiver.ResultType() != reflect.TypeOf("") {
+		t.Errorf("expected result type to be string, got %v", receiver.ResultType())
+	}
+
+	_, err = NewReceiver(func(int, context.Context) {})
+	if errors.Cause(err) != errWrongNumberOfArgs {
+		t.Errorf("expected context as second argument to give relevant error, got: %s", err)
+	}
+
+}
+
+func TestNewChanReceiver(t *testing.T) {
+
+	done := make(chan struct{})
+
+	_, err := NewChanReceiver("string", done)
+	if errors.Cause(err) != errNotAChannel {
+		t.Errorf("expected non-channel receiver to give relevant error, got: %s", err)
+	}
+
+	_, err = NewChanReceiver(make(<-chan int), done)
+	if errors.Cause(err) != errRecvOnly {
+		t.Errorf("expected receive-only channel to give relevant error, got: %s", err)
+	}
+
+	ch := make(chan int, 1)
+	receiver, err := NewChanReceiver(ch, done)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	receiver.function.Call([]reflect.Value{reflect.ValueOf(1)})
+	if actual := <-ch; actual != 1 {
+		t.Errorf("expected value to be forwarded to channel, got %d", actual)
+	}
+
+	close(done)
+	receiver.function.Call([]reflect.Value{reflect.ValueOf(2)})
+	receiver.function.Call([]reflect.Value{reflect.ValueOf(3)}) // should not block
+
+}
+
+func TestReceiver_receive_Context(t *testing.T) {
+
+	var (
+		actualEnv   *Envelope
+		hasDeadline bool
+		handled     error
+	)
+	expected := errors.New("handler error")
+	receiver, err := NewReceiver(func(ctx context.Context, msg int) error {
+		actualEnv = EnvelopeFromContext(ctx)
+		_, hasDeadline = ctx.Deadline()
+		return expected
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	receiver.SetTimeout(time.Second)
+	receiver.SetErrorHandler(func(err error) { handled = err })
+
+	env := &Envelope{CorrelationID: "id"}
+	receiver.receive(env, reflect.ValueOf(1))
+
+	if actualEnv != env {
+		t.Errorf("expected message envelope to be given in context, got %v", actualEnv)
+	}
+	if !hasDeadline {
+		t.Error("expected context to have a deadline when timeout is set")
+	}
+	if handled != expected {
+		t.Errorf("expected returned error to be handled, got %v", handled)
+	}
+
+}
+
+func TestReceiver_receive_Result(t *testing.T) {
+
+	receiver, err := NewReceiver(func(msg int) (int, error) {
+		if msg < 0 {
+			return 0, errors.New("negative")
+		}
+		return msg * 2, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = receiver.SetResultSender(NewDirectSender(reflect.TypeOf("")))
+	if errors.Cause(err) != errIncompatibleResult {
+		t.Errorf("expected incompatible result sender to give relevant error, got: %s", err)
+	}
+
+	results := NewDirectSender(reflect.TypeOf(0))
+	if err = receiver.SetResultSender(results); err != nil {
+		t.Fatal(err)
+	}
+
+	var actual []int
+	collector, _ := NewReceiver(func(msg int) { actual = append(actual, msg) })
+	results.Subscribe(collector)
+
+	env := &Envelope{CorrelationID: "id"}
+	receiver.receive(env, reflect.ValueOf(2))
+	receiver.receive(env, reflect.ValueOf(-1))
+
+	if len(actual) != 1 || actual[0] != 4 {
+		t.Errorf("expected only successful result to be sent, got %v", actual)
+	}
+
+}
+
+func TestReceiver_SetReorder(t *testing.T) {
+
+	var actual []int
+	receiver, err := NewReceiver(func(msg int) { actual = append(actual, msg) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver.SetReorder(0, 0)
+
+	for _, seq := range []int{2, 1, 3} {
+		receiver.receive(&Envelope{Source: "a", Sequence: uint64(seq)}, reflect.ValueOf(seq))
+	}
+
+	if !reflect.DeepEqual(actual, []int{1, 2, 3}) {
+		t.Errorf("expected messages to be handled in sequence order, got %v", actual)
+	}
+
+}
+
+func TestReceiver_SetReorder_Filtered(t *testing.T) {
+
+	var actual []int
+	receiver, err := NewReceiver(func(msg int) { actual = append(actual, msg) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := receiver.SetReorder(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	filter, err := NewFilter(func(v int) bool { return v != 2 })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sender := NewDirectSender(reflect.TypeOf(0))
+	subs, err := sender.Subscribe(receiver, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subs.Close()
+	for _, v := range []int{1, 2, 3} {
+		sender.Send(nil, reflect.ValueOf(v))
+	}
+
+	if !reflect.DeepEqual(actual, []int{1, 3}) {
+		t.Errorf("expected filtered message not to be waited on, got %v", actual)
+	}
+
+}
+
+func TestReceiver_SetReorder_Unsubscribed(t *testing.T) {
+
+	receiver, err := NewReceiver(func(msg int) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sender := NewDirectSender(reflect.TypeOf(0))
+	subs, err := sender.Subscribe(receiver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := receiver.SetReorder(0, 0); errors.Cause(err) != errReorderSubscribed {
+		t.Errorf("expected subscribed receiver not to be reordered, got %v", err)
+	}
+
+	subs.Close()
+	subs.Close()
+	if err := receiver.SetReorder(0, 0); err != nil {
+		t.Errorf("expected receiver to be reordered once unsubscribed, got %v", err)
+	}
+
 }
@@ -0,0 +1,257 @@
+package churncore
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Reorder buffers messages that arrive out of sequence and releases
+// them in sequence order. Sequences are tracked independently for
+// each source, and are expected to start at one unless a different
+// start is expected for that source
+type Reorder struct {
+	window  int
+	timeout time.Duration
+	key     func(*Envelope) (source string, sequence uint64)
+	release func(*Envelope, reflect.Value)
+
+	mutex   sync.Mutex
+	streams map[string]*reorderStream
+
+	// ready holds the messages waiting to be released in order,
+	// and releasing is set while a single goroutine releases them
+	// without holding the mutex, so releases are never interleaved
+	ready     []queuedMessage
+	releasing bool
+}
+
+type reorderStream struct {
+	next    uint64
+	pending map[uint64][]queuedMessage
+	count   int
+	timer   *time.Timer
+	// timers counts the timers started, so that one which
+	// fires after being stopped can tell that it is stale
+	timers uint64
+}
+
+// NewReorder creates a reorder buffer which passes messages to
+// 'release' in the order of the sequence returned by 'key'. At most
+// 'window' messages are buffered for each source before the oldest
+// gap in the sequence is skipped, and no gap is waited on for longer
+// than 'timeout'. A zero window or timeout disables that limit
+func NewReorder(
+	window int,
+	timeout time.Duration,
+	key func(*Envelope) (source string, sequence uint64),
+	release func(*Envelope, reflect.Value),
+) *Reorder {
+
+	return &Reorder{
+		window:  window,
+		timeout: timeout,
+		key:     key,
+		release: release,
+		streams: make(map[string]*reorderStream),
+	}
+
+}
+
+// BySource orders messages by the sequence that their
+// sender gave them, which is the default for in ports
+func BySource(env *Envelope) (string, uint64) {
+	return env.Source, env.Sequence
+}
+
+// Add buffers a single message, releasing it and any
+// following messages if it is next in sequence
+func (r *Reorder) Add(env *Envelope, val reflect.Value) {
+
+	r.add(env, &queuedMessage{env, val})
+	r.releaseReady()
+
+}
+
+// Drop records that the message with the given envelope will never
+// be added, so that its place in the sequence is not waited on
+func (r *Reorder) Drop(env *Envelope) {
+
+	r.add(env, nil)
+	r.releaseReady()
+
+}
+
+// add buffers a message, or marks its place in the
+// sequence as filled if 'msg' is nil
+func (r *Reorder) add(env *Envelope, msg *queuedMessage) {
+
+	source, seq := r.key(env)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stream, ok := r.streams[source]
+	if !ok {
+		stream = newReorderStream(1)
+		r.streams[source] = stream
+	}
+
+	// messages from before the current position have already
+	// been skipped over, so there is no order to restore
+	if seq < stream.next {
+		if msg != nil {
+			r.ready = append(r.ready, *msg)
+		}
+		return
+	}
+
+	if msg != nil {
+		stream.pending[seq] = append(stream.pending[seq], *msg)
+		stream.count++
+	} else if _, ok := stream.pending[seq]; !ok {
+		stream.pending[seq] = nil
+	}
+	next := stream.next
+	r.flush(stream)
+
+	for r.window > 0 && stream.count > r.window {
+		r.skip(stream)
+	}
+
+	// the timeout applies to the current gap, so it
+	// starts again whenever the sequence moves on
+	if (stream.count == 0 || stream.next != next) && stream.timer != nil {
+		stream.timer.Stop()
+		stream.timer = nil
+	}
+	if stream.count > 0 && stream.timer == nil {
+		r.startTimer(stream)
+	}
+
+}
+
+// expect sets the sequence of the next message from 'source',
+// unless messages from that source have already been added
+func (r *Reorder) expect(source string, next uint64) {
+
+	r.mutex.Lock()
+	if _, ok := r.streams[source]; !ok {
+		r.streams[source] = newReorderStream(next)
+	}
+	r.mutex.Unlock()
+
+}
+
+func newReorderStream(next uint64) *reorderStream {
+
+	return &reorderStream{
+		next:    next,
+		pending: make(map[uint64][]queuedMessage),
+	}
+
+}
+
+// Pending returns the number of messages currently buffered
+func (r *Reorder) Pending() int {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	count := 0
+	for _, stream := range r.streams {
+		count += stream.count
+	}
+	return count
+
+}
+
+// flush readies all messages that are next in sequence
+// to be released. Must be called while holding the mutex
+func (r *Reorder) flush(stream *reorderStream) {
+
+	for {
+		msgs, ok := stream.pending[stream.next]
+		if !ok {
+			return
+		}
+		delete(stream.pending, stream.next)
+		stream.count -= len(msgs)
+		stream.next++
+		r.ready = append(r.ready, msgs...)
+	}
+
+}
+
+// releaseReady releases all readied messages, unless another
+// goroutine is already doing so and will release them in turn
+func (r *Reorder) releaseReady() {
+
+	r.mutex.Lock()
+	if r.releasing {
+		r.mutex.Unlock()
+		return
+	}
+	r.releasing = true
+	for len(r.ready) > 0 {
+		ready := r.ready
+		r.ready = nil
+		r.mutex.Unlock()
+		for _, msg := range ready {
+			r.release(msg.env, msg.val)
+		}
+		r.mutex.Lock()
+	}
+	r.releasing = false
+	r.mutex.Unlock()
+
+}
+
+// skip gives up on the current gap in the sequence,
+// releasing messages up to the next gap
+func (r *Reorder) skip(stream *reorderStream) {
+
+	if stream.count == 0 {
+		return
+	}
+	seqs := make([]uint64, 0, len(stream.pending))
+	for seq := range stream.pending {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	stream.next = seqs[0]
+	r.flush(stream)
+
+}
+
+// startTimer starts waiting on the current gap in the sequence,
+// if there is a timeout. Must be called while holding the mutex
+func (r *Reorder) startTimer(stream *reorderStream) {
+
+	if r.timeout <= 0 {
+		return
+	}
+	stream.timers++
+	id := stream.timers
+	stream.timer = time.AfterFunc(r.timeout, func() { r.expire(stream, id) })
+
+}
+
+// expire is called when a gap has been waited on for too long
+func (r *Reorder) expire(stream *reorderStream, id uint64) {
+
+	r.mutex.Lock()
+	if stream.timers != id || stream.timer == nil {
+		// the timer was stopped after it fired
+		r.mutex.Unlock()
+		return
+	}
+	stream.timer = nil
+	r.skip(stream)
+	if stream.count > 0 {
+		r.startTimer(stream)
+	}
+	r.mutex.Unlock()
+	r.releaseReady()
+
+}
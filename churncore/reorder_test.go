@@ -0,0 +1,124 @@
+package churncore
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReorder(t *testing.T) {
+
+	var actual []uint64
+	r := NewReorder(0, 0, BySource, func(env *Envelope, val reflect.Value) {
+		actual = append(actual, env.Sequence)
+	})
+
+	for _, seq := range []uint64{2, 3, 1, 5, 4} {
+		r.Add(&Envelope{Source: "a", Sequence: seq}, reflect.ValueOf(0))
+	}
+	r.Add(&Envelope{Source: "b", Sequence: 1}, reflect.ValueOf(0))
+
+	expected := []uint64{1, 2, 3, 4, 5, 1}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected messages in sequence order, got %v", actual)
+	}
+
+}
+
+func TestReorder_Drop(t *testing.T) {
+
+	var (
+		r      *Reorder
+		actual []uint64
+	)
+	r = NewReorder(0, 0, BySource, func(env *Envelope, val reflect.Value) {
+		// releases are made without holding the reorder's lock
+		r.Pending()
+		actual = append(actual, env.Sequence)
+	})
+
+	for _, seq := range []uint64{3, 1} {
+		r.Add(&Envelope{Sequence: seq}, reflect.ValueOf(0))
+	}
+	r.Drop(&Envelope{Sequence: 2})
+
+	expected := []uint64{1, 3}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected dropped message not to be waited on, got %v", actual)
+	}
+
+}
+
+func TestReorder_Window(t *testing.T) {
+
+	var actual []uint64
+	r := NewReorder(2, 0, BySource, func(env *Envelope, val reflect.Value) {
+		actual = append(actual, env.Sequence)
+	})
+
+	for _, seq := range []uint64{3, 4, 5, 1} {
+		r.Add(&Envelope{Sequence: seq}, reflect.ValueOf(0))
+	}
+
+	expected := []uint64{3, 4, 5, 1}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected gap to be skipped once window is full, got %v", actual)
+	}
+
+}
+
+func TestReorder_Timeout(t *testing.T) {
+
+	released := make(chan uint64, 2)
+	r := NewReorder(0, time.Millisecond, BySource, func(env *Envelope, val reflect.Value) {
+		released <- env.Sequence
+	})
+
+	r.Add(&Envelope{Sequence: 3}, reflect.ValueOf(0))
+	r.Add(&Envelope{Sequence: 2}, reflect.ValueOf(0))
+
+	for _, expected := range []uint64{2, 3} {
+		select {
+		case actual := <-released:
+			if actual != expected {
+				t.Errorf("expected sequence %d after timeout, got %d", expected, actual)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for gap to expire")
+		}
+	}
+
+	if r.Pending() != 0 {
+		t.Errorf("expected no pending messages, got %d", r.Pending())
+	}
+
+}
+
+func TestReorder_TimeoutRestarts(t *testing.T) {
+
+	released := make(chan uint64, 4)
+	r := NewReorder(0, 50*time.Millisecond, BySource, func(env *Envelope, val reflect.Value) {
+		released <- env.Sequence
+	})
+
+	// a message is always pending, but each gap is filled
+	// within the timeout and so none of them are skipped
+	r.Add(&Envelope{Sequence: 2}, reflect.ValueOf(0))
+	r.Add(&Envelope{Sequence: 4}, reflect.ValueOf(0))
+	time.Sleep(30 * time.Millisecond)
+	r.Add(&Envelope{Sequence: 1}, reflect.ValueOf(0))
+	time.Sleep(30 * time.Millisecond)
+	r.Add(&Envelope{Sequence: 3}, reflect.ValueOf(0))
+
+	for expected := uint64(1); expected <= 4; expected++ {
+		select {
+		case actual := <-released:
+			if actual != expected {
+				t.Fatalf("expected sequence %d, got %d", expected, actual)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for messages")
+		}
+	}
+
+}
@@ -0,0 +1,155 @@
+package churncore
+
+import (
+	"math/rand"
+	"reflect"
+	"sync"
+)
+
+// Scheduler delivers messages one at a time from the goroutine that
+// steps it, rather than from the goroutines of their senders, so that
+// the order in which messages are handled can be reproduced. Messages
+// are delivered in the order that they were sent, or in a random order
+// that still delivers the messages of each subscription in sequence
+type Scheduler struct {
+	random *rand.Rand
+
+	// stepLock is held for the whole of each step,
+	// while mutex guards the pending messages
+	stepLock sync.Mutex
+	mutex    sync.Mutex
+	pending  []scheduledMessage
+	senders  []*Sender
+}
+
+type scheduledMessage struct {
+	subs *Subscription
+	msg  queuedMessage
+}
+
+// bufferedSend is a value read from a scheduled sender's
+// channel that has not yet been given to the scheduler
+type bufferedSend struct {
+	parent  *Envelope
+	headers map[string]string
+	val     reflect.Value
+}
+
+// NewScheduler creates a scheduler which delivers messages in the
+// order that they were sent, or in an order chosen by 'random'
+// if it is not nil
+func NewScheduler(random *rand.Rand) *Scheduler {
+	return &Scheduler{random: random}
+}
+
+// Step delivers a single pending message, returning false
+// if there were no messages to deliver. The receiver is called
+// from the current goroutine, and any messages that it sends are
+// scheduled rather than delivered
+func (s *Scheduler) Step() bool {
+
+	s.stepLock.Lock()
+	defer s.stepLock.Unlock()
+
+	s.collect()
+
+	s.mutex.Lock()
+	next, ok := s.next()
+	s.mutex.Unlock()
+	if !ok {
+		return false
+	}
+
+	select {
+	case <-next.subs.done:
+		// messages for closed subscriptions are dropped
+		next.subs.drop(next.msg.env)
+	default:
+		next.subs.deliver(next.msg.env, next.msg.val)
+		next.subs.handled()
+	}
+	return true
+
+}
+
+// Pending returns the number of messages waiting to be delivered
+func (s *Scheduler) Pending() int {
+
+	s.stepLock.Lock()
+	defer s.stepLock.Unlock()
+
+	s.collect()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.pending)
+
+}
+
+// schedule adds a message to be delivered to a subscription
+func (s *Scheduler) schedule(subs *Subscription, env *Envelope, val reflect.Value) {
+
+	s.mutex.Lock()
+	s.pending = append(s.pending, scheduledMessage{subs, queuedMessage{env, val}})
+	s.mutex.Unlock()
+
+}
+
+// register adds a channel sender whose values are collected
+// before each step
+func (s *Scheduler) register(sender *Sender) {
+
+	s.mutex.Lock()
+	s.senders = append(s.senders, sender)
+	s.mutex.Unlock()
+
+}
+
+// collect waits for every registered sender to finish reading from
+// its channel, and then sends their values in registration order
+// so that the goroutines of the channel readers cannot affect it
+func (s *Scheduler) collect() {
+
+	s.mutex.Lock()
+	senders := s.senders
+	s.mutex.Unlock()
+
+	for _, sender := range senders {
+		sender.sync()
+	}
+	for _, sender := range senders {
+		sender.flushBuffered()
+	}
+
+}
+
+// next removes and returns the next message to deliver.
+// Must be called while holding the mutex
+func (s *Scheduler) next() (scheduledMessage, bool) {
+
+	if len(s.pending) == 0 {
+		return scheduledMessage{}, false
+	}
+
+	index := 0
+	if s.random != nil {
+		// choose between subscriptions rather than messages
+		// so that each subscription stays in order
+		var heads []int
+		seen := make(map[*Subscription]bool)
+		for i, msg := range s.pending {
+			if !seen[msg.subs] {
+				seen[msg.subs] = true
+				heads = append(heads, i)
+			}
+		}
+		index = heads[s.random.Intn(len(heads))]
+	}
+
+	next := s.pending[index]
+	copy(s.pending[index:], s.pending[index+1:])
+	s.pending[len(s.pending)-1] = scheduledMessage{}
+	s.pending = s.pending[:len(s.pending)-1]
+	return next, true
+
+}
@@ -1,8 +1,25 @@
+// Package churncore provides the message passing primitives that
+// churn graphs are built from.
+//
+// Ordering: a sender delivers messages to each of its subscriptions in
+// the order that it sends them, and a queued subscription hands them to
+// its receiver in that same order. This holds for every sender that
+// reads from a go channel, since a single goroutine does all sending.
+// Direct senders may be sent to from several goroutines at once, and
+// concurrent sends are only ordered by their sequence numbers. No order
+// is guaranteed between messages from different senders, or between
+// messages that pass through different subscriptions, such as replicas
+// or a join; use a Reorder to restore the sequence where it matters.
+// A Scheduler replaces all of these goroutines with a single one,
+// which delivers every message in a reproducible order.
 package churncore
 
 import (
+	"context"
 	"reflect"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/satori/go.uuid"
 
@@ -19,10 +36,37 @@ var (
 type Sender struct {
 	dataType reflect.Type
 	channel  reflect.Value
-	subs     map[uuid.UUID]*Subscription
+	subs     []*Subscription
+	delivery Delivery
+	sequence uint64
+	sent     uint64
+	source   func() string
+	lineage  *Lineage
 
-	// mutex is held anytime a value is being sent or
-	// the subscription set is being modified
+	// scheduler is set for senders whose messages are delivered
+	// by a scheduler, in which case channel values are buffered
+	// until the scheduler syncs with the channel reader
+	scheduler *Scheduler
+	buffered  []bufferedSend
+
+	// syncs are answered by the channel reader once it has read
+	// every value already in the channel, and stopped is closed
+	// when it stops reading
+	syncs   chan chan struct{}
+	stopped chan struct{}
+
+	// read is closed each time the reader stops waiting on the
+	// channel, and sending is set while it sends what it read.
+	// Values that arrive in the meantime are related by the tags
+	// of each sync, of which 'tagged' values are still unread
+	read     chan struct{}
+	sending  bool
+	tags     []channelTag
+	tagged   int
+	readLock sync.Mutex
+
+	// mutex is held anytime the subscription set, delivery
+	// mode or buffered values are being accessed
 	mutex sync.Mutex
 }
 
@@ -31,6 +75,16 @@ type Sender struct {
 // consume all channel values until the channel is closed
 func NewSender(channel interface{}) (*Sender, error) {
 
+	return NewScheduledSender(channel, nil)
+
+}
+
+// NewScheduledSender creates a new message sender using the given go
+// channel, like NewSender, whose messages are delivered by 'scheduler'
+// if it is not nil. Values read from the channel are only sent when
+// the scheduler next steps
+func NewScheduledSender(channel interface{}, scheduler *Scheduler) (*Sender, error) {
+
 	chanVal := reflect.ValueOf(channel)
 	chanType := chanVal.Type()
 
@@ -45,63 +99,460 @@ func NewSender(channel interface{}) (*Sender, error) {
 	s := &Sender{
 		dataType: chanType.Elem(),
 		channel:  chanVal,
-		subs:     make(map[uuid.UUID]*Subscription),
+		delivery: Broadcast(),
+		syncs:    make(chan chan struct{}),
+		stopped:  make(chan struct{}),
 	}
 
-	go func() {
-		alive := true
-		for alive {
-			alive = s.handleOne()
-		}
-	}()
+	if scheduler != nil {
+		s.scheduler = scheduler
+		scheduler.register(s)
+		go s.bufferAll()
+		return s, nil
+	}
+
+	go s.readAll()
 
 	return s, nil
 
 }
 
+// channelTag relates the next 'count' values read from the
+// channel of a sender to the parent that they were written for
+type channelTag struct {
+	count   int
+	parent  *Envelope
+	headers map[string]string
+}
+
+// NewDirectSender creates a message sender of the given data type
+// that has no backing go channel. Messages are only produced by the
+// receivers whose results have been directed to it
+func NewDirectSender(dataType reflect.Type) *Sender {
+
+	return &Sender{
+		dataType: dataType,
+		delivery: Broadcast(),
+	}
+
+}
+
+// DataType returns the type of message produced by this sender
+func (s *Sender) DataType() reflect.Type {
+	return s.dataType
+}
+
+// Sent returns the number of messages sent so far
+func (s *Sender) Sent() uint64 {
+	return atomic.LoadUint64(&s.sent)
+}
+
+// SetSource sets a function that provides the path of this
+// sender, as recorded in the envelope of each message sent
+func (s *Sender) SetSource(source func() string) {
+	s.source = source
+}
+
+// sourcePath returns the path recorded in the
+// envelopes of this sender's messages
+func (s *Sender) sourcePath() string {
+
+	if s.source == nil {
+		return ""
+	}
+	return s.source()
+
+}
+
+// SetLineage sets the lineage that relates each message read from
+// this sender's channel to the message that caused it to be sent
+func (s *Sender) SetLineage(lineage *Lineage) {
+
+	s.lineage = lineage
+	if lineage != nil && s.channel.IsValid() {
+		lineage.track(s)
+	}
+
+}
+
+// SetScheduler makes the given scheduler deliver all messages
+// sent by this direct sender. Channel senders must instead be
+// created with NewScheduledSender
+func (s *Sender) SetScheduler(scheduler *Scheduler) {
+	if !s.channel.IsValid() {
+		s.scheduler = scheduler
+	}
+}
+
+// SetDelivery changes how messages are distributed
+// between the subscribers of this sender
+func (s *Sender) SetDelivery(delivery Delivery) error {
+
+	if err := delivery.check(s.dataType); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := delivery.(broadcast); !ok {
+		for _, sub := range s.subs {
+			if sub.receiver.reorder != nil {
+				return errReorderDelivery
+			}
+		}
+	}
+	s.delivery = delivery
+	return nil
+
+}
+
 // Subscribe creates a subscription from this sender to the
 // given receiver, which will cause the receiver's underlying
 // function to be called for every sent value until the
-// subscription is closed
-func (s *Sender) Subscribe(r *Receiver) (*Subscription, error) {
+// subscription is closed. Each value is first passed through the
+// given transforms in order, whose types must line up from the
+// sender to the receiver
+func (s *Sender) Subscribe(r *Receiver, transforms ...*Transform) (*Subscription, error) {
+	return s.SubscribeWith(r, SubscribeOptions{}, transforms...)
+}
 
-	if !s.dataType.AssignableTo(r.dataType) {
+// SubscribeWith is like Subscribe, but the subscription is configured
+// by 'options' before any message can be sent to it
+func (s *Sender) SubscribeWith(
+	r *Receiver,
+	options SubscribeOptions,
+	transforms ...*Transform,
+) (*Subscription, error) {
+
+	dataType := s.dataType
+	for _, t := range transforms {
+		if !dataType.AssignableTo(t.inType) {
+			return nil, errors.Wrapf(
+				errIncompatibleTransform,
+				"cannot assign [%s] -> %s", dataType, t,
+			)
+		}
+		dataType = t.outType
+	}
+
+	if !dataType.AssignableTo(r.dataType) {
 		return nil, errors.Wrapf(
 			errIncompatibleReceiver,
-			"cannot assign [%s] -> [%s]", s.dataType, r.dataType,
+			"cannot assign [%s] -> [%s]", dataType, r.dataType,
 		)
 	}
 
-	id := uuid.NewV4()
+	if r.reorder != nil {
+		s.mutex.Lock()
+		_, ok := s.delivery.(broadcast)
+		s.mutex.Unlock()
+		if !ok {
+			return nil, errReorderDelivery
+		}
+	}
+	atomic.AddInt32(&r.subscribed, 1)
+
 	subs := &Subscription{
-		sender:   s,
-		receiver: r,
-		onClose: func() {
-			s.mutex.Lock()
-			delete(s.subs, id)
-			s.mutex.Unlock()
-		},
+		sender:     s,
+		receiver:   r,
+		transforms: transforms,
+		done:       make(chan struct{}),
+		onDrop:     options.OnDrop,
+		once:       options.Once,
+	}
+	if options.Delay {
+		subs.startDelay()
+	} else if options.QueueSize > 0 {
+		subs.startQueue(options.QueueSize)
 	}
 
 	s.mutex.Lock()
-	s.subs[id] = subs
+	s.subs = append(s.subs, subs)
+	if r.reorder != nil {
+		// every message sent from now on reaches the subscription,
+		// while earlier ones may never arrive to be waited on
+		r.reorder.expect(s.sourcePath(), atomic.LoadUint64(&s.sequence)+1)
+	}
 	s.mutex.Unlock()
 
 	return subs, nil
 
 }
 
-// returns false if the underlying channel has been
-// closed or became nil
-func (s *Sender) handleOne() (wasHandled bool) {
+// unsubscribe removes the given subscription from this sender
+func (s *Sender) unsubscribe(subs *Subscription) {
 
-	val, ok := s.channel.Recv()
-	if !ok {
-		return ok
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i, sub := range s.subs {
+		if sub == subs {
+			// copy so that in-flight sends keep a consistent view
+			s.subs = append(s.subs[:i:i], s.subs[i+1:]...)
+			return
+		}
+	}
+
+}
+
+// readAll reads and sends every channel value, relating each one
+// to the message being handled when it was written
+func (s *Sender) readAll() {
+
+	defer close(s.stopped)
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: s.channel},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(s.syncs)},
+	}
+	for {
+		s.readLock.Lock()
+		s.sending = false
+		s.read = make(chan struct{})
+		s.readLock.Unlock()
+
+		chosen, val, ok := reflect.Select(cases)
+
+		var (
+			batch []bufferedSend
+			ack   chan struct{}
+		)
+		s.readLock.Lock()
+		close(s.read)
+		if chosen == 0 {
+			if !ok {
+				s.readLock.Unlock()
+				return
+			}
+			batch = append(batch, s.relate(val))
+		} else {
+			// values already in the channel were written before
+			// the sync, and so are related before answering it
+			ack = val.Interface().(chan struct{})
+			for {
+				val, ok := s.channel.TryRecv()
+				if !ok {
+					break
+				}
+				batch = append(batch, s.relate(val))
+			}
+		}
+		s.sending = true
+		s.readLock.Unlock()
+
+		if ack != nil {
+			close(ack)
+		}
+		for _, b := range batch {
+			s.send(b.parent, b.headers, b.val)
+		}
 	}
-	for _, sub := range s.subs {
-		sub.receiver.function.Call([]reflect.Value{val})
+
+}
+
+// relate finds the parent and headers of a value read from the
+// channel, which were captured by a sync if it happened while the
+// value waited in the channel. Must be called while holding the read lock
+func (s *Sender) relate(val reflect.Value) bufferedSend {
+
+	if len(s.tags) == 0 {
+		parent, headers := s.lineage.derive()
+		return bufferedSend{parent, headers, val}
+	}
+	tag := &s.tags[0]
+	b := bufferedSend{tag.parent, copyHeaders(tag.headers), val}
+	tag.count--
+	s.tagged--
+	if tag.count == 0 {
+		s.tags = s.tags[1:]
+	}
+	return b
+
+}
+
+// bufferAll reads every channel value into the buffer, and
+// answers syncs once the channel has been drained
+func (s *Sender) bufferAll() {
+
+	defer close(s.stopped)
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: s.channel},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(s.syncs)},
+	}
+	for {
+		chosen, val, ok := reflect.Select(cases)
+		if chosen == 0 {
+			if !ok {
+				return
+			}
+			s.buffer(val)
+			continue
+		}
+
+		ack := val.Interface().(chan struct{})
+		for {
+			val, ok := s.channel.TryRecv()
+			if !ok {
+				close(ack)
+				// a valid value means that the channel is closed
+				// rather than empty
+				if val.IsValid() {
+					return
+				}
+				break
+			}
+			s.buffer(val)
+		}
+	}
+
+}
+
+func (s *Sender) buffer(val reflect.Value) {
+
+	parent, headers := s.lineage.derive()
+	s.mutex.Lock()
+	s.buffered = append(s.buffered, bufferedSend{parent, headers, val})
+	s.mutex.Unlock()
+
+}
+
+// sync waits until every value that has been sent into the channel
+// of this sender has been read, and related to its parent message.
+// Values that wait in the channel while the reader is busy sending
+// are related to their parent right away instead
+func (s *Sender) sync() {
+
+	if s.scheduler != nil {
+		ack := make(chan struct{})
+		select {
+		case s.syncs <- ack:
+			<-ack
+		case <-s.stopped:
+		}
+		return
+	}
+
+	for {
+		s.readLock.Lock()
+		if s.sending {
+			if n := s.channel.Len() - s.tagged; n > 0 {
+				parent, headers := s.lineage.derive()
+				s.tags = append(s.tags, channelTag{n, parent, headers})
+				s.tagged += n
+			}
+			s.readLock.Unlock()
+			return
+		}
+		read := s.read
+		s.readLock.Unlock()
+
+		ack := make(chan struct{})
+		select {
+		case s.syncs <- ack:
+			<-ack
+			return
+		case <-read:
+			// a value was read first, and the reader
+			// may now be busy sending it
+		case <-s.stopped:
+			return
+		}
+	}
+
+}
+
+// flushBuffered sends all buffered channel values
+func (s *Sender) flushBuffered() {
+
+	s.mutex.Lock()
+	buffered := s.buffered
+	s.buffered = nil
+	s.mutex.Unlock()
+
+	for _, b := range buffered {
+		s.send(b.parent, b.headers, b.val)
+	}
+
+}
+
+// Send sends a single message from this sender, whose envelope is
+// derived from 'parent' if it is not nil. The message must be of
+// the sender's data type
+func (s *Sender) Send(parent *Envelope, val reflect.Value) {
+
+	var headers map[string]string
+	if parent != nil {
+		headers = copyHeaders(parent.Headers)
+	}
+	s.send(parent, headers, val)
+
+}
+
+// Forward delivers a message that has already been sent
+// elsewhere, keeping its original envelope
+func (s *Sender) Forward(env *Envelope, val reflect.Value) {
+
+	s.forward(context.Background(), env, val)
+
+}
+
+// SendContext is like Send, but gives up waiting for queued
+// subscriptions to accept the message once 'ctx' is done,
+// returning its error. Receivers that are called directly
+// are not interrupted
+func (s *Sender) SendContext(ctx context.Context, parent *Envelope, val reflect.Value) error {
+
+	var headers map[string]string
+	if parent != nil {
+		headers = copyHeaders(parent.Headers)
+	}
+	return s.forward(ctx, s.envelope(parent, headers), val)
+
+}
+
+func (s *Sender) forward(ctx context.Context, env *Envelope, val reflect.Value) error {
+
+	atomic.AddUint64(&s.sent, 1)
+	s.mutex.Lock()
+	subs, delivery := s.subs, s.delivery
+	s.mutex.Unlock()
+
+	for _, sub := range delivery.route(val, subs) {
+		if err := sub.enqueue(ctx, env, val); err != nil {
+			return err
+		}
+	}
+	return nil
+
+}
+
+// send stamps a new envelope for a single message and
+// delivers it to the subscribers chosen by the delivery mode
+func (s *Sender) send(parent *Envelope, headers map[string]string, val reflect.Value) {
+
+	s.Forward(s.envelope(parent, headers), val)
+
+}
+
+// envelope stamps a new envelope for a message
+func (s *Sender) envelope(parent *Envelope, headers map[string]string) *Envelope {
+
+	env := &Envelope{
+		Time:     time.Now(),
+		Sequence: atomic.AddUint64(&s.sequence, 1),
+		Headers:  headers,
+		Parent:   parent,
+	}
+	if s.source != nil {
+		env.Source = s.source()
+	}
+	if parent != nil {
+		env.CorrelationID = parent.CorrelationID
+		env.depth = parent.depth + 1
+		if env.depth > MaxAncestry {
+			env.Parent = trimAncestry(parent, MaxAncestry/2)
+			env.depth = MaxAncestry / 2
+		}
+	} else {
+		env.CorrelationID = uuid.NewV4().String()
 	}
-	return true
+	return env
 
 }
@@ -1,7 +1,9 @@
 package churncore
 
 import (
+	"context"
 	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
@@ -101,3 +103,91 @@ func TestSender_Subscribe_Invalid(t *testing.T) {
 	}
 
 }
+
+func TestSender_Envelope(t *testing.T) {
+
+	ch := make(chan string)
+	sender, err := NewSender(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lineage := new(Lineage)
+	sender.SetLineage(lineage)
+	sender.SetSource(func() string { return "Node.Port" })
+
+	envs := make(chan *Envelope, 2)
+	receiver, err := NewReceiver(func(ctx context.Context, msg string) {
+		envs <- EnvelopeFromContext(ctx)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender.Subscribe(receiver)
+
+	ch <- "first"
+	first := <-envs
+	if first.Sequence != 1 || first.Source != "Node.Port" || first.Parent != nil {
+		t.Errorf("unexpected envelope for first message: %+v", first)
+	}
+
+	parent := &Envelope{CorrelationID: "parent", Headers: map[string]string{"key": "value"}}
+	lineage.receive(parent)
+	ch <- "second"
+	second := <-envs
+	if second.Sequence != 2 || second.Parent != parent || second.CorrelationID != "parent" {
+		t.Errorf("expected second message to derive from lineage: %+v", second)
+	}
+	if second.Header("key") != "value" {
+		t.Errorf("expected headers to be copied from parent, got %v", second.Headers)
+	}
+
+	close(ch)
+
+}
+
+func TestSender_EnvelopeAncestry(t *testing.T) {
+
+	sender := NewDirectSender(reflect.TypeOf(0))
+	var env *Envelope
+	for i := 0; i < 3*MaxAncestry; i++ {
+		env = sender.envelope(env, nil)
+	}
+
+	ancestors := 0
+	for parent := env.Parent; parent != nil; parent = parent.Parent {
+		ancestors++
+	}
+	if ancestors == 0 || ancestors > MaxAncestry {
+		t.Errorf("expected at most %d ancestors to be kept, got %d", MaxAncestry, ancestors)
+	}
+	if env.Parent.Sequence != env.Sequence-1 {
+		t.Errorf("expected the most recent ancestors to be kept, got parent %d of %d", env.Parent.Sequence, env.Sequence)
+	}
+
+}
+
+func TestSender_FIFO(t *testing.T) {
+
+	ch := make(chan int)
+	sender, err := NewSender(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan int, 100)
+	receiver, _ := NewReceiver(func(msg int) { received <- msg })
+	subs, _ := sender.SubscribeWith(receiver, SubscribeOptions{QueueSize: 10})
+
+	for i := 0; i < 100; i++ {
+		ch <- i
+	}
+	for i := 0; i < 100; i++ {
+		if actual := <-received; actual != i {
+			t.Fatalf("expected messages in the order sent, got %d at %d", actual, i)
+		}
+	}
+
+	subs.Close()
+	close(ch)
+
+}
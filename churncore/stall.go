@@ -0,0 +1,49 @@
+package churncore
+
+import (
+	"sync"
+	"time"
+)
+
+// stallClock measures how long some work has been waiting
+// to complete without any progress being made
+type stallClock struct {
+	mutex    sync.Mutex
+	waiting  int
+	progress time.Time
+}
+
+// begin marks the start of a single piece of work
+func (c *stallClock) begin() {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.waiting == 0 {
+		c.progress = time.Now()
+	}
+	c.waiting++
+
+}
+
+// end marks the completion of a single piece of work
+func (c *stallClock) end() {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.waiting--
+	c.progress = time.Now()
+
+}
+
+// stalled returns the time since work last completed, or
+// zero if there is no work in progress
+func (c *stallClock) stalled() time.Duration {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.waiting == 0 {
+		return 0
+	}
+	return time.Since(c.progress)
+
+}
@@ -1,16 +1,325 @@
 package churncore
 
+import (
+	"context"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
 // Subscription connects a sender to a compatible receiver
 // and manages the transfer of messages between them
 type Subscription struct {
-	sender   *Sender
-	receiver *Receiver
-	onClose  func()
+	sender     *Sender
+	receiver   *Receiver
+	transforms []*Transform
+
+	// queue is set for subscriptions that deliver messages
+	// from their own goroutine, and is drained until done
+	queue     chan queuedMessage
+	done      chan struct{}
+	closeOnce sync.Once
+	inflight  int64
+
+	// backlog is used instead of the queue for delayed
+	// subscriptions, and can grow without limit
+	backlog     []queuedMessage
+	backlogLock sync.Mutex
+	signal      chan struct{}
+
+	// blocked tracks the sender while it waits on this subscription
+	blocked      stallClock
+	blockedTotal int64
+	delivered    uint64
+
+	// detached subscriptions deliver envelopes without their parent
+	detached bool
+	// onDrop is given the envelope of each message that is
+	// discarded because the subscription was closed
+	onDrop func(*Envelope)
+	// once subscriptions close when they are next idle
+	once bool
+}
+
+type queuedMessage struct {
+	env *Envelope
+	val reflect.Value
+}
+
+// Transforms returns the transforms applied to each message
+// in this subscription, in order
+func (s *Subscription) Transforms() []*Transform {
+	return s.transforms
+}
+
+// SubscribeOptions configures how a new subscription
+// delivers messages to its receiver
+type SubscribeOptions struct {
+	// QueueSize, if not zero, makes the subscription deliver messages
+	// from its own goroutine rather than that of the sender, buffering
+	// up to QueueSize messages before the sender is blocked. This has
+	// no effect when the sender is scheduled
+	QueueSize int
+	// Delay makes the subscription deliver messages from its own
+	// goroutine through a queue that never blocks the sender. This
+	// allows cycles of subscriptions to run without deadlocking, and
+	// so the envelopes of delayed messages are delivered without their
+	// parent so that each trip around a cycle does not accumulate
+	// history. When the sender is scheduled only the envelopes are
+	// affected, since the scheduler never blocks. Delay takes
+	// precedence over QueueSize
+	Delay bool
+	// OnDrop, if set, is called with the envelope of each message
+	// that is discarded because the subscription was closed before
+	// the message could be delivered
+	OnDrop func(*Envelope)
+	// Once closes the subscription as soon as no message given
+	// to it is waiting to be handled, which for a scheduled sender
+	// is not until the message has been stepped
+	Once bool
+}
+
+// startQueue starts delivering messages from a queue of the
+// given size. Must be called before the subscription is added
+// to its sender
+func (s *Subscription) startQueue(size int) {
+
+	if s.sender.scheduler != nil {
+		return
+	}
+	s.queue = make(chan queuedMessage, size)
+	go func() {
+		for {
+			select {
+			case <-s.done:
+				return
+			case msg := <-s.queue:
+				s.deliver(msg.env, msg.val)
+				s.handled()
+			}
+		}
+	}()
+
+}
+
+// startDelay starts delivering messages from a backlog that never
+// blocks the sender. Must be called before the subscription is
+// added to its sender
+func (s *Subscription) startDelay() {
+
+	s.detached = true
+	if s.sender.scheduler != nil {
+		return
+	}
+	s.signal = make(chan struct{}, 1)
+	go func() {
+		for {
+			s.backlogLock.Lock()
+			if len(s.backlog) == 0 {
+				s.backlogLock.Unlock()
+				select {
+				case <-s.done:
+					return
+				case <-s.signal:
+				}
+				continue
+			}
+			msg := s.backlog[0]
+			s.backlog[0] = queuedMessage{}
+			s.backlog = s.backlog[1:]
+			s.backlogLock.Unlock()
+
+			s.deliver(msg.env, msg.val)
+			s.handled()
+		}
+	}()
+
+}
+
+// Load returns the number of messages that have been
+// given to this subscription but not yet fully handled
+func (s *Subscription) Load() int {
+
+	return int(atomic.LoadInt64(&s.inflight))
+
 }
 
-// Close ends this subscription
+// Stalled returns how long the sender has been waiting to hand
+// messages to this subscription without any being accepted, or
+// zero if the sender is not currently waiting
+func (s *Subscription) Stalled() time.Duration {
+	return s.blocked.stalled()
+}
+
+// Delivered returns the number of messages that have been
+// passed to the receiver, after any were dropped by transforms
+func (s *Subscription) Delivered() uint64 {
+	return atomic.LoadUint64(&s.delivered)
+}
+
+// BlockedTime returns the total time that the sender has spent
+// waiting on this subscription, which includes the time spent
+// in the receiver when messages are delivered directly
+func (s *Subscription) BlockedTime() time.Duration {
+	return time.Duration(atomic.LoadInt64(&s.blockedTotal))
+}
+
+// enqueue delivers a message directly, or by way of the scheduler,
+// queue or backlog, returning the error of 'ctx' if it is done
+// before the message is accepted
+func (s *Subscription) enqueue(ctx context.Context, env *Envelope, val reflect.Value) error {
+
+	// messages add to the load from the moment that they are
+	// accepted until they have been delivered or dropped
+	atomic.AddInt64(&s.inflight, 1)
+
+	if s.sender.scheduler != nil {
+		s.sender.scheduler.schedule(s, env, val)
+		return nil
+	}
+
+	if s.signal != nil {
+		s.backlogLock.Lock()
+		s.backlog = append(s.backlog, queuedMessage{env, val})
+		s.backlogLock.Unlock()
+		select {
+		case s.signal <- struct{}{}:
+		default:
+		}
+		return nil
+	}
+
+	s.blocked.begin()
+	start := time.Now()
+	defer func() {
+		atomic.AddInt64(&s.blockedTotal, int64(time.Since(start)))
+		s.blocked.end()
+	}()
+
+	if s.queue == nil {
+		defer s.handled()
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s.deliver(env, val)
+		return nil
+	}
+	select {
+	case s.queue <- queuedMessage{env, val}:
+	case <-s.done:
+		s.drop(env)
+	case <-ctx.Done():
+		s.handled()
+		return ctx.Err()
+	}
+	return nil
+
+}
+
+// deliver transforms and passes a single message to the receiver
+func (s *Subscription) deliver(env *Envelope, val reflect.Value) {
+
+	if s.detached {
+		detached := *env
+		detached.Parent = nil
+		detached.depth = 0
+		env = &detached
+	}
+
+	for _, t := range s.transforms {
+		var (
+			ok  bool
+			err error
+		)
+		val, ok, err = t.function(val)
+		if err != nil && s.receiver.onError != nil {
+			s.receiver.onError(errors.Wrap(err, t.String()))
+		}
+		if !ok {
+			// a reordered receiver would otherwise wait
+			// on the place of the message in its sequence
+			if s.receiver.reorder != nil {
+				s.receiver.reorder.Drop(env)
+			}
+			return
+		}
+	}
+	atomic.AddUint64(&s.delivered, 1)
+	s.receiver.receive(env, val)
+
+}
+
+// Close ends this subscription, discarding any queued messages
 func (s *Subscription) Close() {
-	if s.onClose != nil {
-		s.onClose()
+
+	s.closeOnce.Do(func() {
+		s.sender.unsubscribe(s)
+		atomic.AddInt32(&s.receiver.subscribed, -1)
+		close(s.done)
+	})
+
+	for drained := false; !drained; {
+		select {
+		case msg := <-s.queue:
+			s.drop(msg.env)
+		default:
+			drained = true
+		}
 	}
+	s.backlogLock.Lock()
+	backlog := s.backlog
+	s.backlog = nil
+	s.backlogLock.Unlock()
+	for _, msg := range backlog {
+		s.drop(msg.env)
+	}
+
+}
+
+// Drain stops the sender from giving any more messages to this
+// subscription, and waits for those that it was already given to
+// be handled before closing it. If 'ctx' is done first then the
+// subscription is closed straight away. Messages held by a scheduler
+// are never waited on
+func (s *Subscription) Drain(ctx context.Context) {
+
+	s.sender.unsubscribe(s)
+	if s.sender.scheduler == nil {
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for s.Load() > 0 {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				s.Close()
+				return
+			}
+		}
+	}
+	s.Close()
+
+}
+
+// handled records that a message accepted by enqueue
+// has been delivered or dropped
+func (s *Subscription) handled() {
+
+	if atomic.AddInt64(&s.inflight, -1) == 0 && s.once {
+		s.Close()
+	}
+
+}
+
+// drop discards a message accepted by enqueue
+// because the subscription was closed
+func (s *Subscription) drop(env *Envelope) {
+
+	s.handled()
+	if s.onDrop != nil {
+		s.onDrop(env)
+	}
+
 }
@@ -0,0 +1,98 @@
+package churncore
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+// TraceParentHeader is the message header that carries the trace
+// context of the handler call that sent a message, in the format of
+// the W3C traceparent header. Since headers are copied into derived
+// messages, it links each span to the span that caused it
+const TraceParentHeader = "traceparent"
+
+// Span records a single call to a handler function
+type Span struct {
+	// TraceID is shared by all spans that were caused
+	// by the same original message, as 32 hex digits
+	TraceID string
+	// SpanID identifies this span, as 16 hex digits
+	SpanID string
+	// ParentSpanID identifies the span that sent the
+	// handled message, or is empty if there was none
+	ParentSpanID string
+	// Name is the path of the handler that was called
+	Name       string
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]string
+	// Err is the error returned by the handler, if any
+	Err error
+}
+
+// SetTracer sets a function which is consulted before each call to
+// the handler function, and returns the function that records the
+// span of that call. No span is recorded if either function is nil
+func (h *handler) SetTracer(tracer func() func(*Span)) {
+	h.tracer = tracer
+}
+
+// startSpan begins the span of a call to the handler function
+// for the given message, returning nil if it is not traced
+func (h *handler) startSpan(env *Envelope) (*Span, func(*Span)) {
+
+	if h.tracer == nil {
+		return nil, nil
+	}
+	record := h.tracer()
+	if record == nil {
+		return nil, nil
+	}
+
+	span := &Span{
+		Start: time.Now(),
+		Attributes: map[string]string{
+			"churn.source":         env.Source,
+			"churn.correlation_id": env.CorrelationID,
+		},
+	}
+	span.TraceID, span.ParentSpanID = parseTraceParent(env.Header(TraceParentHeader))
+	if span.TraceID == "" {
+		span.TraceID = newTraceID(16)
+	}
+	span.SpanID = newTraceID(8)
+	return span, record
+
+}
+
+// traceParent formats the trace context of a span as a header value
+func (s *Span) traceParent() string {
+	return fmt.Sprintf("00-%s-%s-01", s.TraceID, s.SpanID)
+}
+
+// parseTraceParent returns the trace and span ids of a traceparent
+// header value, or empty strings if it is not valid
+func parseTraceParent(value string) (traceID, spanID string) {
+
+	parts := strings.Split(value, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", ""
+	}
+	if _, err := hex.DecodeString(parts[1] + parts[2]); err != nil {
+		return "", ""
+	}
+	return parts[1], parts[2]
+
+}
+
+// newTraceID returns 'size' random bytes as hex digits
+func newTraceID(size int) string {
+
+	id := uuid.NewV4()
+	return hex.EncodeToString(id[:size])
+
+}
@@ -0,0 +1,108 @@
+package churncore
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	errInvalidMap            = errors.New("map func must take one argument and return one value")
+	errInvalidFilter         = errors.New("filter func must take one argument and return a bool")
+	errIncompatibleTransform = errors.New("incompatible transform type")
+)
+
+// Transform modifies or drops messages as they pass
+// through a subscription
+type Transform struct {
+	name     string
+	inType   reflect.Type
+	outType  reflect.Type
+	function func(reflect.Value) (reflect.Value, bool, error)
+}
+
+// NewMap creates a transform that replaces each message with the
+// result of 'mapFunc', which must be of the form func(T) U
+func NewMap(mapFunc interface{}) (*Transform, error) {
+
+	funcVal := reflect.ValueOf(mapFunc)
+	funcType := funcVal.Type()
+
+	if funcType.Kind() != reflect.Func ||
+		funcType.NumIn() != 1 || funcType.NumOut() != 1 {
+		return nil, errors.Wrapf(errInvalidMap, "invalid type %T", mapFunc)
+	}
+
+	return &Transform{
+		name:    fmt.Sprintf("map(%s)", funcType),
+		inType:  funcType.In(0),
+		outType: funcType.Out(0),
+		function: func(val reflect.Value) (reflect.Value, bool, error) {
+			return funcVal.Call([]reflect.Value{val})[0], true, nil
+		},
+	}, nil
+
+}
+
+// NewFilter creates a transform that drops all messages for which
+// 'predicate' returns false. 'predicate' must be of the form func(T) bool
+func NewFilter(predicate interface{}) (*Transform, error) {
+
+	funcVal := reflect.ValueOf(predicate)
+	funcType := funcVal.Type()
+
+	if funcType.Kind() != reflect.Func ||
+		funcType.NumIn() != 1 || funcType.NumOut() != 1 ||
+		funcType.Out(0).Kind() != reflect.Bool {
+		return nil, errors.Wrapf(errInvalidFilter, "invalid type %T", predicate)
+	}
+
+	return &Transform{
+		name:    fmt.Sprintf("filter(%s)", funcType),
+		inType:  funcType.In(0),
+		outType: funcType.In(0),
+		function: func(val reflect.Value) (reflect.Value, bool, error) {
+			return val, funcVal.Call([]reflect.Value{val})[0].Bool(), nil
+		},
+	}, nil
+
+}
+
+// NewConversion creates a transform that converts each message from
+// one type to another using 'convert'. Messages that fail to convert are
+// dropped, and the error is given to the receiver's error handler
+func NewConversion(from, to reflect.Type, convert func(reflect.Value) (reflect.Value, error)) *Transform {
+
+	return &Transform{
+		name:    fmt.Sprintf("convert(%s -> %s)", from, to),
+		inType:  from,
+		outType: to,
+		function: func(val reflect.Value) (reflect.Value, bool, error) {
+			val, err := convert(val)
+			return val, err == nil, err
+		},
+	}
+
+}
+
+// String returns a description of this transform
+func (t *Transform) String() string {
+	return t.name
+}
+
+// InType returns the type of message accepted by this transform
+func (t *Transform) InType() reflect.Type {
+	return t.inType
+}
+
+// OutType returns the type of message produced by this transform
+func (t *Transform) OutType() reflect.Type {
+	return t.outType
+}
+
+// Apply transforms a single message, returning false if the
+// message should be dropped
+func (t *Transform) Apply(val reflect.Value) (reflect.Value, bool, error) {
+	return t.function(val)
+}
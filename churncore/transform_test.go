@@ -0,0 +1,63 @@
+package churncore
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestNewMap(t *testing.T) {
+
+	_, err := NewMap(func(int) {})
+	if errors.Cause(err) != errInvalidMap {
+		t.Errorf("expected map func without a result to give relevant error, got: %s", err)
+	}
+
+	m, err := NewMap(strconv.Itoa)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.InType() != reflect.TypeOf(0) || m.OutType() != reflect.TypeOf("") {
+		t.Errorf("expected map types to match function, got %s -> %s", m.InType(), m.OutType())
+	}
+
+}
+
+func TestNewFilter(t *testing.T) {
+
+	_, err := NewFilter(func(int) int { return 0 })
+	if errors.Cause(err) != errInvalidFilter {
+		t.Errorf("expected filter func without bool result to give relevant error, got: %s", err)
+	}
+
+}
+
+func TestSender_Subscribe_Transforms(t *testing.T) {
+
+	sender := NewDirectSender(reflect.TypeOf(0))
+	var actual []string
+	receiver, _ := NewReceiver(func(msg string) { actual = append(actual, msg) })
+
+	even, _ := NewFilter(func(v int) bool { return v%2 == 0 })
+	itoa, _ := NewMap(strconv.Itoa)
+
+	_, err := sender.Subscribe(receiver, itoa, even)
+	if errors.Cause(err) != errIncompatibleTransform {
+		t.Errorf("expected misordered transforms to give relevant error, got: %s", err)
+	}
+
+	_, err = sender.Subscribe(receiver, even, itoa)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 4; i++ {
+		sender.send(nil, nil, reflect.ValueOf(i))
+	}
+	if !reflect.DeepEqual(actual, []string{"2", "4"}) {
+		t.Errorf("expected filtered and mapped messages, got %v", actual)
+	}
+
+}
@@ -0,0 +1,217 @@
+// Package churnhttp connects churn graphs to http servers
+package churnhttp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/rydrman/churn"
+
+	"github.com/satori/go.uuid"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxBodyBytes limits the size of request bodies
+// when no other limit is given
+const DefaultMaxBodyBytes = 1 << 20
+
+var (
+	bytesType        = reflect.TypeOf([]byte(nil))
+	errHandlerClosed = errors.New("handler is closed")
+)
+
+// Options configures a graph handler
+type Options struct {
+	// In is the path of the in port that each request body
+	// is decoded and sent to
+	In string
+	// Out is the path of the out port whose correlated value
+	// is encoded as the response
+	Out string
+
+	// Timeout limits how long a request waits for its response,
+	// in addition to the request's own context. Zero means no limit
+	Timeout time.Duration
+	// MaxBodyBytes limits the size of request bodies, and
+	// defaults to DefaultMaxBodyBytes
+	MaxBodyBytes int64
+}
+
+// handler serves requests by sending them through a graph
+type handler struct {
+	graph   *churn.Graph
+	options Options
+	inType  reflect.Type
+
+	mutex   sync.Mutex
+	waiting map[string]chan interface{}
+
+	// stop ends the observation of the out port, and
+	// closed is closed once it has been called
+	stop   func()
+	closed chan struct{}
+	once   sync.Once
+}
+
+// Handler returns an http handler that calls a graph for each request.
+// Request bodies are decoded into a value for the designated in port,
+// and the value sent from the designated out port with the same
+// correlation id is encoded as the response. Strings and byte slices
+// are passed through as-is, and all other types use json. The out port
+// is observed until the returned close function is called, after which
+// all requests fail, including those still waiting for a response
+func Handler(graph *churn.Graph, options Options) (http.Handler, func(), error) {
+
+	in := graph.GetInPort(options.In)
+	if in == nil {
+		return nil, nil, errors.Wrap(churn.ErrPortNotExist, options.In)
+	}
+	if options.MaxBodyBytes == 0 {
+		options.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+
+	h := &handler{
+		graph:   graph,
+		options: options,
+		inType:  in.DataType(),
+		waiting: make(map[string]chan interface{}),
+		closed:  make(chan struct{}),
+	}
+	stop, err := graph.ObserveEnvelopes(options.Out, h.respond)
+	if err != nil {
+		return nil, nil, err
+	}
+	h.stop = stop
+	return h, h.close, nil
+
+}
+
+// close stops serving requests, and is safe to call more than once
+func (h *handler) close() {
+
+	h.once.Do(func() {
+		h.stop()
+		close(h.closed)
+	})
+
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	select {
+	case <-h.closed:
+		http.Error(w, errHandlerClosed.Error(), http.StatusServiceUnavailable)
+		return
+	default:
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.options.MaxBodyBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	value, err := decode(body, h.inType)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	if h.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.options.Timeout)
+		defer cancel()
+	}
+
+	id := uuid.NewV4().String()
+	response := make(chan interface{}, 1)
+	h.mutex.Lock()
+	h.waiting[id] = response
+	h.mutex.Unlock()
+	defer func() {
+		h.mutex.Lock()
+		delete(h.waiting, id)
+		h.mutex.Unlock()
+	}()
+
+	err = h.graph.Send(churn.WithCorrelationID(ctx, id), h.options.In, value)
+	switch {
+	case churn.IsTypeMismatch(err):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	select {
+	case value := <-response:
+		encode(w, value)
+	case <-h.closed:
+		http.Error(w, errHandlerClosed.Error(), http.StatusServiceUnavailable)
+	case <-ctx.Done():
+		http.Error(w, ctx.Err().Error(), http.StatusGatewayTimeout)
+	}
+
+}
+
+// respond passes an out port value to the request it is correlated with
+func (h *handler) respond(env *churn.Envelope, value interface{}) {
+
+	h.mutex.Lock()
+	response, ok := h.waiting[env.CorrelationID]
+	delete(h.waiting, env.CorrelationID)
+	h.mutex.Unlock()
+	if ok {
+		response <- value
+	}
+
+}
+
+// decode creates a value of the given type from a request body
+func decode(body []byte, dataType reflect.Type) (interface{}, error) {
+
+	switch {
+	case dataType == bytesType:
+		return body, nil
+	case dataType.Kind() == reflect.String:
+		return reflect.ValueOf(string(body)).Convert(dataType).Interface(), nil
+	}
+
+	ptr := reflect.New(dataType)
+	if err := json.Unmarshal(body, ptr.Interface()); err != nil {
+		return nil, err
+	}
+	return ptr.Elem().Interface(), nil
+
+}
+
+// encode writes a value as the body of a response
+func encode(w http.ResponseWriter, value interface{}) {
+
+	switch v := value.(type) {
+	case []byte:
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(v)
+		return
+	case string:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, v)
+		return
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+
+}
@@ -0,0 +1,205 @@
+package churnhttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rydrman/churn"
+)
+
+type upperNode struct{ churn.BaseNode }
+
+func (*upperNode) InText(s string) (string, error) { return strings.ToUpper(s), nil }
+
+type sumNode struct{ churn.BaseNode }
+
+func (*sumNode) InValues(values []int) (map[string]int, error) {
+	sum := 0
+	for _, v := range values {
+		sum += v
+	}
+	return map[string]int{"sum": sum}, nil
+}
+
+// dropNode never produces a result
+type dropNode struct {
+	churn.BaseNode
+	OutText chan string
+}
+
+func (*dropNode) InText(string) {}
+
+func TestHandler(t *testing.T) {
+
+	graph := churn.NewGraph()
+	graph.Add("Upper", new(upperNode))
+	graph.Add("Sum", new(sumNode))
+	defer graph.Close()
+
+	upper, closeUpper, err := Handler(graph, Options{In: "Upper.Text", Out: "Upper.TextResult"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeUpper()
+	sum, closeSum, err := Handler(graph, Options{In: "Sum.Values", Out: "Sum.ValuesResult"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeSum()
+
+	cases := []struct {
+		handler  http.Handler
+		body     string
+		status   int
+		expected string
+	}{
+		{upper, "hello", http.StatusOK, "HELLO"},
+		{sum, "[1, 2, 3]", http.StatusOK, `{"sum":6}`},
+		{sum, "not json", http.StatusBadRequest, ""},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		c.handler.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(c.body)))
+		if w.Code != c.status {
+			t.Errorf("%q: expected status %d, got %d", c.body, c.status, w.Code)
+			continue
+		}
+		if c.status == http.StatusOK && w.Body.String() != c.expected {
+			t.Errorf("%q: expected %q, got %q", c.body, c.expected, w.Body.String())
+		}
+	}
+
+	if _, _, err := Handler(graph, Options{In: "Upper.Missing", Out: "Upper.TextResult"}); !churn.IsPortNotExist(err) {
+		t.Errorf("expected missing port error, got %v", err)
+	}
+
+}
+
+func TestHandler_Timeout(t *testing.T) {
+
+	graph := churn.NewGraph()
+	graph.Add("Drop", new(dropNode))
+	defer graph.Close()
+
+	h, closeHandler, err := Handler(graph, Options{In: "Drop.Text", Out: "Drop.Text", Timeout: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeHandler()
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+	resp, err := http.Post(server.URL, "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Errorf("expected gateway timeout, got %d", resp.StatusCode)
+	}
+
+}
+
+func TestHandler_Close(t *testing.T) {
+
+	graph := churn.NewGraph()
+	graph.Add("Drop", new(dropNode))
+	defer graph.Close()
+
+	h, closeHandler, err := Handler(graph, Options{In: "Drop.Text", Out: "Drop.Text"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a request that is still waiting fails once closed
+	status := make(chan int, 1)
+	go func() {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader("hello")))
+		status <- w.Code
+	}()
+	time.Sleep(10 * time.Millisecond)
+	closeHandler()
+	closeHandler()
+	select {
+	case code := <-status:
+		if code != http.StatusServiceUnavailable {
+			t.Errorf("expected service unavailable, got %d", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected waiting request to fail once closed")
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader("hello")))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected service unavailable, got %d", w.Code)
+	}
+
+}
+
+func TestSourceNode(t *testing.T) {
+
+	graph := churn.NewGraph()
+	source := NewSourceNode()
+	graph.Add("Source", source)
+	defer graph.Close()
+
+	requests, cancel, err := churn.TapTyped[Request](graph, "Source.Request", churn.TapBuffer(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+
+	w := httptest.NewRecorder()
+	source.ServeHTTP(w, httptest.NewRequest("PUT", "/items?id=1", strings.NewReader("data")))
+	if w.Code != http.StatusAccepted {
+		t.Errorf("expected accepted status, got %d", w.Code)
+	}
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second)
+	defer done()
+	select {
+	case req := <-requests:
+		if req.Method != "PUT" || req.Path != "/items" ||
+			req.Query.Get("id") != "1" || string(req.Body) != "data" {
+			t.Errorf("expected request to be described, got %+v", req)
+		}
+	case <-ctx.Done():
+		t.Fatal("expected a request message")
+	}
+
+}
+
+func TestSourceNode_ZeroValue(t *testing.T) {
+
+	graph := churn.NewGraph()
+	source := new(SourceNode)
+	graph.Add("Source", source)
+	defer graph.Close()
+
+	requests, cancel, err := churn.TapTyped[Request](graph, "Source.Request", churn.TapBuffer(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+
+	w := httptest.NewRecorder()
+	source.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader("data")))
+	if w.Code != http.StatusAccepted {
+		t.Errorf("expected body within the default limit to be accepted, got %d", w.Code)
+	}
+	select {
+	case req := <-requests:
+		if string(req.Body) != "data" {
+			t.Errorf("expected request body, got %q", req.Body)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a request message")
+	}
+
+}
@@ -0,0 +1,72 @@
+package churnhttp
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/rydrman/churn"
+)
+
+// Request is the message sent by a SourceNode for each http request
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// SourceNode is a graph component that is also an http handler,
+// sending a message for every request that it serves. Requests
+// are answered with 202 Accepted once their message has been sent
+type SourceNode struct {
+	churn.BaseNode
+
+	// OutRequest is the output port of this node
+	OutRequest chan Request
+
+	// MaxBodyBytes limits the size of request bodies, and
+	// defaults to DefaultMaxBodyBytes if it is not positive
+	MaxBodyBytes int64
+}
+
+// NewSourceNode creates an http source node. The node owns its
+// out port so that requests served after the graph is closed
+// are never sent on a closed channel
+func NewSourceNode() *SourceNode {
+
+	return &SourceNode{
+		OutRequest:   make(chan Request),
+		MaxBodyBytes: DefaultMaxBodyBytes,
+	}
+
+}
+
+func (n *SourceNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	limit := n.MaxBodyBytes
+	if limit <= 0 {
+		limit = DefaultMaxBodyBytes
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	req := Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header,
+		Body:   body,
+	}
+	select {
+	case n.OutRequest <- req:
+		w.WriteHeader(http.StatusAccepted)
+	case <-r.Context().Done():
+		http.Error(w, r.Context().Err().Error(), http.StatusServiceUnavailable)
+	}
+
+}
@@ -0,0 +1,18 @@
+package churnmetrics
+
+import (
+	"expvar"
+
+	"github.com/rydrman/churn"
+)
+
+// Publish exposes the statistics of 'graph' as an expvar variable
+// with the given name, which is served by the expvar http handler.
+// Like expvar.Publish, this panics if the name is already in use
+func Publish(name string, graph *churn.Graph) {
+
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return graph.Stats()
+	}))
+
+}
@@ -0,0 +1,100 @@
+// Package churnmetrics exports the statistics of churn graphs
+package churnmetrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rydrman/churn"
+)
+
+// Handler returns an http handler that serves the statistics
+// of 'graph' in the prometheus text exposition format
+func Handler(graph *churn.Graph) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WritePrometheus(w, graph.Stats())
+	})
+
+}
+
+// WritePrometheus writes the given statistics in the
+// prometheus text exposition format
+func WritePrometheus(w io.Writer, stats churn.Stats) error {
+
+	b := bufio.NewWriter(w)
+
+	header(b, "churn_port_messages_total", "counter", "Messages sent by out ports and received by in ports.")
+	for _, port := range stats.Ports {
+		sample(b, "churn_port_messages_total", labels("port", port.Path, "direction", port.Direction), float64(port.Messages))
+	}
+
+	header(b, "churn_handler_calls_total", "counter", "Calls made to in port handler functions.")
+	for _, h := range stats.Handlers {
+		sample(b, "churn_handler_calls_total", labels("handler", h.Path), float64(h.Calls))
+	}
+	header(b, "churn_handler_errors_total", "counter", "Errors returned by in port handler functions.")
+	for _, h := range stats.Handlers {
+		sample(b, "churn_handler_errors_total", labels("handler", h.Path), float64(h.Errors))
+	}
+	header(b, "churn_handler_panics_total", "counter", "Panics recovered from in port handler functions.")
+	for _, h := range stats.Handlers {
+		sample(b, "churn_handler_panics_total", labels("handler", h.Path), float64(h.Panics))
+	}
+	header(b, "churn_handler_latency_seconds", "histogram", "Time spent in in port handler functions.")
+	for _, h := range stats.Handlers {
+		var cumulative uint64
+		for i, count := range h.Latency.Counts {
+			cumulative += count
+			le := "+Inf"
+			if i < len(h.Latency.Buckets) {
+				le = strconv.FormatFloat(h.Latency.Buckets[i].Seconds(), 'g', -1, 64)
+			}
+			sample(b, "churn_handler_latency_seconds_bucket", labels("handler", h.Path, "le", le), float64(cumulative))
+		}
+		sample(b, "churn_handler_latency_seconds_sum", labels("handler", h.Path), h.Latency.Sum.Seconds())
+		sample(b, "churn_handler_latency_seconds_count", labels("handler", h.Path), float64(h.Latency.Count))
+	}
+
+	header(b, "churn_connection_delivered_total", "counter", "Messages delivered through connections.")
+	for _, c := range stats.Connections {
+		sample(b, "churn_connection_delivered_total", labels("source", c.Source, "dest", c.Dest), float64(c.Delivered))
+	}
+	header(b, "churn_connection_queue_depth", "gauge", "Messages waiting to be handled through connections.")
+	for _, c := range stats.Connections {
+		sample(b, "churn_connection_queue_depth", labels("source", c.Source, "dest", c.Dest), float64(c.QueueDepth))
+	}
+	header(b, "churn_connection_blocked_seconds_total", "counter", "Time that out ports have spent waiting on connections.")
+	for _, c := range stats.Connections {
+		sample(b, "churn_connection_blocked_seconds_total", labels("source", c.Source, "dest", c.Dest), c.Blocked.Seconds())
+	}
+
+	return b.Flush()
+
+}
+
+func header(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func sample(w io.Writer, name, labels string, value float64) {
+	fmt.Fprintf(w, "%s{%s} %s\n", name, labels, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labels formats alternating label names and values
+func labels(pairs ...string) string {
+
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, pairs[i], labelEscaper.Replace(pairs[i+1])))
+	}
+	return strings.Join(parts, ",")
+
+}
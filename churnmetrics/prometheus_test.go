@@ -0,0 +1,91 @@
+package churnmetrics
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rydrman/churn"
+)
+
+type doubleNode struct{ churn.BaseNode }
+
+func (*doubleNode) InValue(v int64) (int64, error) {
+	if v < 0 {
+		panic("negative")
+	}
+	return v * 2, nil
+}
+
+func TestHandler(t *testing.T) {
+
+	errs := make(chan error, 1)
+	graph := churn.NewGraph(churn.ErrorHandler(func(err error) { errs <- err }))
+	source := new(churn.IntNode)
+	graph.Add("Source", source)
+	graph.Add("Double", new(doubleNode))
+	defer graph.Close()
+	graph.Connect("Source.Value", "Double.Value")
+
+	source.OutValue <- 1
+	source.OutValue <- -1
+	select {
+	case err := <-errs:
+		if !churn.IsPanic(err) {
+			t.Errorf("expected panic error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the panic to be reported")
+	}
+
+	w := httptest.NewRecorder()
+	Handler(graph).ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+
+	expected := []string{
+		`churn_port_messages_total{port="Source.Value",direction="out"} 2`,
+		`churn_port_messages_total{port="Double.Value",direction="in"} 2`,
+		`churn_handler_calls_total{handler="Double.Value"} 2`,
+		`churn_handler_errors_total{handler="Double.Value"} 1`,
+		`churn_handler_panics_total{handler="Double.Value"} 1`,
+		`churn_handler_latency_seconds_bucket{handler="Double.Value",le="+Inf"} 2`,
+		`churn_handler_latency_seconds_count{handler="Double.Value"} 2`,
+		`churn_connection_delivered_total{source="Source.Value",dest="Double.Value"} 2`,
+		`churn_connection_queue_depth{source="Source.Value",dest="Double.Value"} 0`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("expected metrics to contain %q, got:\n%s", line, body)
+		}
+	}
+
+}
+
+var published int
+
+func TestPublish(t *testing.T) {
+
+	graph := churn.NewGraph()
+	graph.Add("Double", new(doubleNode))
+	defer graph.Close()
+	graph.Send(context.Background(), "Double.Value", int64(1))
+
+	// expvar names can only be published once per process
+	published++
+	name := fmt.Sprintf("churn_test_%d", published)
+	Publish(name, graph)
+
+	var stats churn.Stats
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Handlers) != 1 || stats.Handlers[0].Path != "Double.Value" {
+		t.Errorf("expected handler stats to be published, got %+v", stats.Handlers)
+	}
+
+}
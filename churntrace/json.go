@@ -0,0 +1,180 @@
+// Package churntrace exports the spans of traced churn graphs
+package churntrace
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/rydrman/churn"
+)
+
+const (
+	// ServiceName is the name of the service that
+	// exported spans are attributed to
+	ServiceName = "churn"
+
+	spanKindInternal = 1
+	statusCodeError  = 2
+)
+
+// JSONExporter writes spans in the OpenTelemetry protocol JSON
+// encoding, with each span written as a single line holding a
+// complete trace export request
+type JSONExporter struct {
+	mutex  sync.Mutex
+	writer io.Writer
+	closer io.Closer
+	err    error
+}
+
+// NewJSONExporter creates a span exporter that writes to 'w'
+func NewJSONExporter(w io.Writer) *JSONExporter {
+	return &JSONExporter{writer: w}
+}
+
+// CreateFile creates or truncates the named file, and returns
+// an exporter that writes spans to it until closed
+func CreateFile(filename string) (*JSONExporter, error) {
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONExporter{writer: f, closer: f}, nil
+
+}
+
+// ExportSpan writes a single span. Once writing has failed
+// all further spans are dropped, and the error is kept
+func (e *JSONExporter) ExportSpan(span *churn.Span) {
+
+	line, err := json.Marshal(exportRequest(span))
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.err != nil {
+		return
+	}
+	if err == nil {
+		_, err = e.writer.Write(append(line, '\n'))
+	}
+	e.err = err
+
+}
+
+// Err returns the first error encountered while writing spans
+func (e *JSONExporter) Err() error {
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.err
+
+}
+
+// Close closes the file of exporters created with CreateFile,
+// returning the first error encountered while writing spans
+func (e *JSONExporter) Close() error {
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.closer != nil {
+		if err := e.closer.Close(); e.err == nil {
+			e.err = err
+		}
+		e.closer = nil
+	}
+	return e.err
+
+}
+
+type request struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []attribute `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope scope  `json:"scope"`
+	Spans []span `json:"spans"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type span struct {
+	TraceID           string      `json:"traceId"`
+	SpanID            string      `json:"spanId"`
+	ParentSpanID      string      `json:"parentSpanId,omitempty"`
+	Name              string      `json:"name"`
+	Kind              int         `json:"kind"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+	Attributes        []attribute `json:"attributes,omitempty"`
+	Status            *status     `json:"status,omitempty"`
+}
+
+type attribute struct {
+	Key   string         `json:"key"`
+	Value attributeValue `json:"value"`
+}
+
+type attributeValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// exportRequest builds the export request for a single span
+func exportRequest(s *churn.Span) request {
+
+	out := span{
+		TraceID:           s.TraceID,
+		SpanID:            s.SpanID,
+		ParentSpanID:      s.ParentSpanID,
+		Name:              s.Name,
+		Kind:              spanKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+		Attributes:        attributes(s.Attributes),
+	}
+	if s.Err != nil {
+		out.Status = &status{Code: statusCodeError, Message: s.Err.Error()}
+	}
+
+	return request{ResourceSpans: []resourceSpans{{
+		Resource: resource{Attributes: attributes(map[string]string{
+			"service.name": ServiceName,
+		})},
+		ScopeSpans: []scopeSpans{{
+			Scope: scope{Name: "github.com/rydrman/churn"},
+			Spans: []span{out},
+		}},
+	}}}
+
+}
+
+// attributes converts a map of attributes, sorted by key
+func attributes(values map[string]string) []attribute {
+
+	attrs := make([]attribute, 0, len(values))
+	for k, v := range values {
+		attrs = append(attrs, attribute{k, attributeValue{v}})
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Key < attrs[j].Key })
+	return attrs
+
+}
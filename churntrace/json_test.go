@@ -0,0 +1,52 @@
+package churntrace
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rydrman/churn"
+
+	"github.com/pkg/errors"
+)
+
+func TestJSONExporter(t *testing.T) {
+
+	buf := new(bytes.Buffer)
+	exporter := NewJSONExporter(buf)
+	start := time.Unix(1, 0)
+	exporter.ExportSpan(&churn.Span{
+		TraceID:      "0af7651916cd43dd8448eb211c80319c",
+		SpanID:       "b7ad6b7169203331",
+		ParentSpanID: "00f067aa0ba902b7",
+		Name:         "Node.Value",
+		Start:        start,
+		End:          start.Add(time.Millisecond),
+		Err:          errors.New("failed"),
+	})
+	exporter.ExportSpan(&churn.Span{Name: "Node.Other"})
+	if err := exporter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("expected one line per span, got %d", len(lines))
+	}
+	var req request
+	if err := json.Unmarshal(lines[0], &req); err != nil {
+		t.Fatal(err)
+	}
+	s := req.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	if s.TraceID != "0af7651916cd43dd8448eb211c80319c" || s.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("expected span ids to be kept, got %+v", s)
+	}
+	if s.StartTimeUnixNano != "1000000000" || s.EndTimeUnixNano != "1001000000" {
+		t.Errorf("expected times in unix nanoseconds, got %+v", s)
+	}
+	if s.Status == nil || s.Status.Code != statusCodeError || s.Status.Message != "failed" {
+		t.Errorf("expected an error status, got %+v", s.Status)
+	}
+
+}
@@ -0,0 +1,289 @@
+"use strict";
+
+const svgNS = "http://www.w3.org/2000/svg";
+const nodeWidth = 220;
+const rowHeight = 18;
+const columnGap = 100;
+const nodeGap = 30;
+
+const state = {
+	topology: { components: [], connections: [] },
+	update: null,
+	selected: null,
+	peeking: null,
+};
+
+async function fetchJSON(url, options) {
+	const response = await fetch(url, options);
+	if (!response.ok) {
+		throw new Error(await response.text());
+	}
+	return response.status === 204 ? null : response.json();
+}
+
+async function loadTopology() {
+	state.topology = await fetchJSON("api/graph");
+	render();
+}
+
+// columns places each node one column to the right of
+// the furthest node that sends to it
+function columns(nodes, connections) {
+	const column = new Map(nodes.map((n) => [n.path, 0]));
+	const owner = (portPath) => {
+		const dot = portPath.lastIndexOf(".");
+		return dot < 0 ? portPath : portPath.slice(0, dot);
+	};
+	for (let i = 0; i < nodes.length; i++) {
+		let changed = false;
+		for (const conn of connections) {
+			if (conn.delayed) {
+				continue;
+			}
+			const from = column.get(owner(conn.source));
+			const to = column.get(owner(conn.dest));
+			if (from !== undefined && to !== undefined && to <= from) {
+				column.set(owner(conn.dest), from + 1);
+				changed = true;
+			}
+		}
+		if (!changed) {
+			break;
+		}
+	}
+	return column;
+}
+
+function render() {
+	const svg = document.getElementById("topology");
+	svg.replaceChildren();
+
+	const nodes = state.topology.components.filter((c) => c.kind === "node");
+	const column = columns(nodes, state.topology.connections);
+	const heights = [];
+	const ports = new Map();
+
+	for (const node of nodes) {
+		const col = column.get(node.path);
+		const rows = Math.max((node.ins || []).length, (node.outs || []).length);
+		const height = (rows + 2) * rowHeight;
+		const x = 20 + col * (nodeWidth + columnGap);
+		const y = 20 + (heights[col] || 0);
+		heights[col] = (heights[col] || 0) + height + nodeGap;
+
+		const group = element("g", { class: nodeClass(node), transform: `translate(${x},${y})` });
+		group.appendChild(element("rect", { width: nodeWidth, height }));
+		const title = element("text", { class: "title", x: 8, y: rowHeight });
+		title.textContent = node.path;
+		title.addEventListener("click", () => select(node.path));
+		group.appendChild(title);
+
+		(node.ins || []).forEach((port, i) => {
+			const path = `${node.path}.${port.name}`;
+			const py = (i + 2) * rowHeight - 4;
+			ports.set("in " + path, [x, y + py]);
+			group.appendChild(portElement(path, "in", port, 8, py, "start"));
+		});
+		(node.outs || []).forEach((port, i) => {
+			const path = `${node.path}.${port.name}`;
+			const py = (i + 2) * rowHeight - 4;
+			ports.set("out " + path, [x + nodeWidth, y + py]);
+			group.appendChild(portElement(path, "out", port, nodeWidth - 8, py, "end"));
+		});
+		svg.appendChild(group);
+	}
+
+	for (const conn of state.topology.connections) {
+		const from = ports.get("out " + conn.source);
+		const to = ports.get("in " + conn.dest);
+		if (!from || !to) {
+			continue;
+		}
+		const bend = Math.max(40, Math.abs(to[0] - from[0]) / 2);
+		const d = `M${from[0]},${from[1]} C${from[0] + bend},${from[1]} ${to[0] - bend},${to[1]} ${to[0]},${to[1]}`;
+		svg.insertBefore(element("path", { class: conn.delayed ? "edge delayed" : "edge", d }), svg.firstChild);
+	}
+
+	const width = 40 + heights.length * (nodeWidth + columnGap);
+	const height = 40 + Math.max(0, ...heights);
+	svg.setAttribute("width", width);
+	svg.setAttribute("height", height);
+	renderDetails();
+}
+
+function nodeClass(node) {
+	let name = "node";
+	if (node.path === state.selected) {
+		name += " selected";
+	}
+	if (node.paused) {
+		name += " paused";
+	}
+	return name;
+}
+
+function portElement(path, direction, port, x, y, anchor) {
+	const text = element("text", {
+		class: "port" + (portErrors(path) > 0 ? " errored" : ""),
+		x, y, "text-anchor": anchor,
+	});
+	const rate = portRate(path, direction);
+	text.textContent = rate === undefined ? port.name : `${port.name} ${formatRate(rate)}`;
+	text.addEventListener("click", () => peek(path));
+	const title = element("title", {});
+	title.textContent = `${direction} ${path} [${port.type}]`;
+	text.appendChild(title);
+	return text;
+}
+
+function element(name, attributes) {
+	const el = document.createElementNS(svgNS, name);
+	for (const [key, value] of Object.entries(attributes)) {
+		el.setAttribute(key, value);
+	}
+	return el;
+}
+
+function portRate(path, direction) {
+	if (!state.update) {
+		return undefined;
+	}
+	const port = state.update.ports.find((p) => p.path === path && p.direction === direction);
+	return port && port.rate;
+}
+
+function portMessages(path, direction) {
+	const port = state.update && state.update.stats.Ports.find(
+		(p) => p.Path === path && p.Direction === direction);
+	return port ? port.Messages : 0;
+}
+
+function handlerFor(path) {
+	const handlers = (state.update && state.update.stats.Handlers) || [];
+	return handlers.find((h) => h.Path === path || path.startsWith(h.Path + ":"));
+}
+
+function portErrors(path) {
+	const handler = handlerFor(path);
+	return handler ? handler.Errors : 0;
+}
+
+function formatRate(rate) {
+	return rate >= 100 ? `${Math.round(rate)}/s` : `${rate.toFixed(1)}/s`;
+}
+
+function formatLatency(handler) {
+	if (!handler || handler.Latency.Count === 0) {
+		return "-";
+	}
+	const ms = handler.Latency.Sum / handler.Latency.Count / 1e6;
+	return `${ms.toFixed(2)}ms`;
+}
+
+function select(path) {
+	state.selected = path;
+	render();
+}
+
+function renderDetails() {
+	const details = document.getElementById("details");
+	const node = state.topology.components.find((c) => c.path === state.selected);
+	if (!node) {
+		details.innerHTML = '<p class="hint">Select a node to see its ports</p>';
+		return;
+	}
+
+	const heading = document.createElement("h2");
+	heading.textContent = `${node.path} (${node.type})`;
+	const button = document.createElement("button");
+	button.textContent = node.paused ? "Resume" : "Pause";
+	button.addEventListener("click", () => togglePause(node));
+
+	const table = document.createElement("table");
+	table.innerHTML = "<tr><th></th><th>port</th><th>msgs</th><th>rate</th><th>errors</th><th>latency</th></tr>";
+	const rows = [
+		...(node.ins || []).map((p) => ["in", p]),
+		...(node.outs || []).map((p) => ["out", p]),
+	];
+	for (const [direction, port] of rows) {
+		const path = `${node.path}.${port.name}`;
+		const handler = direction === "in" ? handlerFor(path) : undefined;
+		const rate = portRate(path, direction);
+		const row = table.insertRow();
+		for (const [value, numeric] of [
+			[direction, false],
+			[port.name, false],
+			[portMessages(path, direction), true],
+			[rate === undefined ? "-" : formatRate(rate), true],
+			[handler ? handler.Errors : "-", true],
+			[formatLatency(handler), true],
+		]) {
+			const cell = row.insertCell();
+			cell.textContent = value;
+			if (numeric) {
+				cell.className = "number";
+			}
+		}
+		row.cells[1].classList.add("port");
+		row.cells[1].addEventListener("click", () => peek(path));
+	}
+	details.replaceChildren(heading, button, table);
+}
+
+async function togglePause(node) {
+	const action = node.paused ? "resume" : "pause";
+	await fetchJSON(`api/${action}?node=${encodeURIComponent(node.path)}`, { method: "POST" });
+	await loadTopology();
+}
+
+async function peek(path) {
+	state.peeking = path;
+	document.getElementById("peek-port").textContent = path;
+	await refreshPeek();
+}
+
+async function refreshPeek() {
+	if (!state.peeking) {
+		return;
+	}
+	const values = await fetchJSON(`api/peek?port=${encodeURIComponent(state.peeking)}`);
+	const list = document.getElementById("peek-values");
+	list.replaceChildren(...values.reverse().map((v) => {
+		const item = document.createElement("li");
+		item.textContent = `${new Date(v.time).toLocaleTimeString()} ${JSON.stringify(v.value)}`;
+		return item;
+	}));
+}
+
+function addError(e) {
+	const list = document.getElementById("errors");
+	const item = document.createElement("li");
+	item.textContent = `${new Date(e.time).toLocaleTimeString()} ${e.path}: ${e.error}`;
+	list.prepend(item);
+	while (list.children.length > 100) {
+		list.lastChild.remove();
+	}
+}
+
+function connect() {
+	const status = document.getElementById("status");
+	const events = new EventSource("api/events");
+	events.onopen = () => {
+		status.textContent = "live";
+		status.className = "live";
+		loadTopology();
+	};
+	events.onerror = () => {
+		status.textContent = "disconnected";
+		status.className = "";
+	};
+	events.addEventListener("stats", (e) => {
+		state.update = JSON.parse(e.data);
+		render();
+		refreshPeek();
+	});
+	events.addEventListener("graph", () => loadTopology());
+	events.addEventListener("node-error", (e) => addError(JSON.parse(e.data)));
+}
+
+connect();
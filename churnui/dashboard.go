@@ -0,0 +1,187 @@
+// Package churnui serves a live web dashboard for running churn graphs
+package churnui
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/rydrman/churn"
+)
+
+const (
+	// DefaultInterval is the time between live
+	// updates when no other interval is given
+	DefaultInterval = time.Second
+	// DefaultPeekSize is the number of recent values kept
+	// for each peeked port when no other size is given
+	DefaultPeekSize = 20
+)
+
+//go:embed assets
+var assets embed.FS
+
+// Options configures a dashboard
+type Options struct {
+	// Interval is the time between the live updates sent to
+	// each dashboard client, and defaults to DefaultInterval
+	Interval time.Duration
+	// PeekSize is the number of recent values kept for each
+	// peeked port, and defaults to DefaultPeekSize
+	PeekSize int
+}
+
+// Dashboard is an http handler that serves a live view of a graph.
+// Alongside the dashboard page, it serves the following api:
+//
+//	GET  api/graph            the topology of the graph
+//	GET  api/stats            a snapshot of churn.Stats
+//	GET  api/events           server-sent stats, graph and node-error events
+//	POST api/pause?node=path  pauses a node
+//	POST api/resume?node=path resumes a node
+//	GET  api/peek?port=path   recent values of a port
+//
+// All paths are relative, so the dashboard can be served under
+// any prefix using http.StripPrefix. Requests to pause or resume
+// that a browser made from another site are forbidden
+type Dashboard struct {
+	graph   *churn.Graph
+	options Options
+	mux     *http.ServeMux
+
+	// done is closed when the dashboard is closed,
+	// ending all event streams
+	done      chan struct{}
+	closeOnce sync.Once
+
+	peekLock sync.Mutex
+	peeks    map[string]*peek
+}
+
+// New creates a dashboard for the given graph
+func New(graph *churn.Graph, options Options) *Dashboard {
+
+	if options.Interval <= 0 {
+		options.Interval = DefaultInterval
+	}
+	if options.PeekSize <= 0 {
+		options.PeekSize = DefaultPeekSize
+	}
+
+	d := &Dashboard{
+		graph:   graph,
+		options: options,
+		mux:     http.NewServeMux(),
+		done:    make(chan struct{}),
+		peeks:   make(map[string]*peek),
+	}
+	static, err := fs.Sub(assets, "assets")
+	if err != nil {
+		panic(err)
+	}
+	d.mux.Handle("GET /", http.FileServer(http.FS(static)))
+	d.mux.HandleFunc("GET /api/graph", d.serveGraph)
+	d.mux.HandleFunc("GET /api/stats", d.serveStats)
+	d.mux.HandleFunc("GET /api/events", d.serveEvents)
+	d.mux.HandleFunc("POST /api/pause", d.servePause(graph.Pause))
+	d.mux.HandleFunc("POST /api/resume", d.servePause(graph.Resume))
+	d.mux.HandleFunc("GET /api/peek", d.servePeek)
+	return d
+
+}
+
+// ListenAndServe serves a dashboard for the given graph on the tcp
+// network address 'addr', such as "localhost:8080", until it fails
+func ListenAndServe(addr string, graph *churn.Graph, options Options) error {
+
+	d := New(graph, options)
+	defer d.Close()
+	return http.ListenAndServe(addr, d)
+
+}
+
+// ServeHTTP serves the dashboard page and api
+func (d *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mux.ServeHTTP(w, r)
+}
+
+// Close ends all event streams and stops peeking at ports
+func (d *Dashboard) Close() {
+
+	d.closeOnce.Do(func() { close(d.done) })
+	d.peekLock.Lock()
+	defer d.peekLock.Unlock()
+	for portPath, p := range d.peeks {
+		p.cancel()
+		delete(d.peeks, portPath)
+	}
+
+}
+
+func (d *Dashboard) serveGraph(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, Describe(d.graph))
+}
+
+func (d *Dashboard) serveStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, d.graph.Stats())
+}
+
+// sameOrigin returns false for requests that a browser reports
+// as coming from another site. Requests without the headers that
+// browsers send are not made from a page, and so are allowed
+func sameOrigin(r *http.Request) bool {
+
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "":
+	case "same-origin", "none":
+		return true
+	default:
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+
+}
+
+// servePause returns a handler that pauses or resumes a node
+func (d *Dashboard) servePause(pause func(nodePath string) error) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !sameOrigin(r) {
+			http.Error(w, "cross-origin request", http.StatusForbidden)
+			return
+		}
+		if err := pause(r.URL.Query().Get("node")); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+}
+
+func (d *Dashboard) servePeek(w http.ResponseWriter, r *http.Request) {
+
+	values, err := d.peek(r.URL.Query().Get("port"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, values)
+
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+
+}
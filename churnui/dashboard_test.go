@@ -0,0 +1,202 @@
+package churnui
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rydrman/churn"
+)
+
+type doubleNode struct{ churn.BaseNode }
+
+func (*doubleNode) InValue(v int64) (int64, error) { return v * 2, nil }
+
+func newTestGraph(t *testing.T) (*churn.Graph, *churn.IntNode) {
+
+	graph := churn.NewGraph()
+	source := new(churn.IntNode)
+	sub := churn.NewGraph()
+	graph.Add("Source", source)
+	graph.Add("Sub", sub)
+	sub.Add("Double", new(doubleNode))
+	if err := graph.Connect("Source.Value", "Sub/Double.Value"); err != nil {
+		t.Fatal(err)
+	}
+	return graph, source
+
+}
+
+func get(t *testing.T, server *httptest.Server, path string, v interface{}) {
+
+	resp, err := http.Get(server.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: %s", path, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+
+}
+
+func TestDashboard(t *testing.T) {
+
+	graph, source := newTestGraph(t)
+	defer graph.Close()
+	dashboard := New(graph, Options{Interval: 10 * time.Millisecond})
+	defer dashboard.Close()
+	server := httptest.NewServer(dashboard)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("expected the dashboard page, got %s %s", resp.Status, resp.Header.Get("Content-Type"))
+	}
+
+	var topology Topology
+	get(t, server, "/api/graph", &topology)
+	paths := make([]string, 0, len(topology.Components))
+	for _, c := range topology.Components {
+		paths = append(paths, c.Path)
+	}
+	if strings.Join(paths, ",") != "Source,Sub,Sub/Double" {
+		t.Errorf("expected components with full paths, got %v", paths)
+	}
+	if len(topology.Connections) != 1 || topology.Connections[0].Dest != "Sub/Double.Value" {
+		t.Errorf("expected the connection with full paths, got %+v", topology.Connections)
+	}
+
+	var values []PeekedValue
+	get(t, server, "/api/peek?port=Sub/Double.ValueResult", &values)
+	source.OutValue <- 21
+	source.OutValue <- 1
+	get(t, server, "/api/peek?port=Sub/Double.ValueResult", &values)
+	if len(values) == 0 || string(values[0].Value) != "42" {
+		t.Errorf("expected the peeked value 42, got %+v", values)
+	}
+
+	resp, err = http.Post(server.URL+"/api/pause?node=Sub/Double", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent || !graph.Paused("Sub/Double") {
+		t.Errorf("expected the node to be paused, got %s", resp.Status)
+	}
+	resp, err = http.Post(server.URL+"/api/resume?node=Missing", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected not found for a missing node, got %s", resp.Status)
+	}
+	graph.Resume("Sub/Double")
+
+}
+
+func TestDashboard_CrossOrigin(t *testing.T) {
+
+	graph, _ := newTestGraph(t)
+	defer graph.Close()
+	dashboard := New(graph, Options{})
+	defer dashboard.Close()
+	server := httptest.NewServer(dashboard)
+	defer server.Close()
+
+	for _, header := range []struct{ name, value string }{
+		{"Sec-Fetch-Site", "cross-site"},
+		{"Origin", "http://example.com"},
+	} {
+		req, err := http.NewRequest("POST", server.URL+"/api/pause?node=Sub/Double", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(header.name, header.value)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden || graph.Paused("Sub/Double") {
+			t.Errorf("expected %s %q to be forbidden, got %s", header.name, header.value, resp.Status)
+		}
+	}
+
+	req, err := http.NewRequest("POST", server.URL+"/api/pause?node=Sub/Double", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Sec-Fetch-Site", "same-origin")
+	req.Header.Set("Origin", server.URL)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent || !graph.Paused("Sub/Double") {
+		t.Errorf("expected a same origin request to pause the node, got %s", resp.Status)
+	}
+	graph.Resume("Sub/Double")
+
+}
+
+func TestDashboard_Events(t *testing.T) {
+
+	graph, source := newTestGraph(t)
+	defer graph.Close()
+	dashboard := New(graph, Options{Interval: 10 * time.Millisecond})
+	defer dashboard.Close()
+	server := httptest.NewServer(dashboard)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	source.OutValue <- 1
+	graph.Add("Other", new(doubleNode))
+
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(resp.Body)
+	var event string
+	for scanner.Scan() && !(seen["stats"] && seen["graph"]) {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "event: ") {
+			event = strings.TrimPrefix(line, "event: ")
+			continue
+		}
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		seen[event] = true
+		if event != "stats" {
+			continue
+		}
+		var update Update
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &update); err != nil {
+			t.Fatal(err)
+		}
+		if len(update.Ports) != len(update.Stats.Ports) {
+			t.Errorf("expected a rate for every port, got %+v", update.Ports)
+		}
+	}
+	if !seen["stats"] || !seen["graph"] {
+		t.Errorf("expected stats and graph events, got %v", seen)
+	}
+
+}
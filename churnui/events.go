@@ -0,0 +1,126 @@
+package churnui
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rydrman/churn"
+)
+
+// Update is the live activity of a graph, sent to
+// dashboard clients as a "stats" event at each interval
+type Update struct {
+	Time  time.Time   `json:"time"`
+	Ports []PortRate  `json:"ports"`
+	Stats churn.Stats `json:"stats"`
+}
+
+// PortRate is the recent message rate of a single port
+type PortRate struct {
+	Path      string `json:"path"`
+	Direction string `json:"direction"`
+	// Rate is the number of messages per second
+	// since the previous update
+	Rate float64 `json:"rate"`
+}
+
+// ErrorEvent is sent to dashboard clients as a "node-error"
+// event for each error returned by a node
+type ErrorEvent struct {
+	Time  time.Time `json:"time"`
+	Path  string    `json:"path"`
+	Error string    `json:"error"`
+}
+
+// eventBuffer is the number of graph events that may be waiting
+// for a slow client before further events are dropped
+const eventBuffer = 64
+
+// serveEvents streams server-sent events to a single client until
+// it disconnects or the dashboard is closed. "graph" events signal
+// that the topology has changed and should be fetched again
+func (d *Dashboard) serveEvents(w http.ResponseWriter, r *http.Request) {
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	// events are delivered from the goroutines of the graph,
+	// which must never wait on the client
+	events := make(chan churn.Event, eventBuffer)
+	cancel := d.graph.Subscribe(func(e churn.Event) {
+		select {
+		case events <- e:
+		default:
+		}
+	})
+	defer cancel()
+
+	ticker := time.NewTicker(d.options.Interval)
+	defer ticker.Stop()
+	var rates rater
+	send(w, "stats", rates.update(d.graph.Stats()))
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-d.done:
+			return
+		case <-ticker.C:
+			send(w, "stats", rates.update(d.graph.Stats()))
+		case e := <-events:
+			if errored, ok := e.(churn.NodeErrored); ok {
+				send(w, "node-error", ErrorEvent{time.Now(), errored.Path, errored.Err.Error()})
+			} else {
+				send(w, "graph", struct{}{})
+			}
+		}
+		flusher.Flush()
+	}
+
+}
+
+// send writes a single server-sent event
+func send(w http.ResponseWriter, event string, data interface{}) {
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, encoded)
+
+}
+
+// rater computes message rates between successive stats
+type rater struct {
+	last     time.Time
+	messages map[string]uint64
+}
+
+func (r *rater) update(stats churn.Stats) Update {
+
+	now := time.Now()
+	elapsed := now.Sub(r.last).Seconds()
+	messages := make(map[string]uint64, len(stats.Ports))
+	update := Update{Time: now, Stats: stats}
+	for _, port := range stats.Ports {
+		key := port.Direction + " " + port.Path
+		messages[key] = port.Messages
+		rate := PortRate{Path: port.Path, Direction: port.Direction}
+		if previous, ok := r.messages[key]; ok && elapsed > 0 && port.Messages >= previous {
+			rate.Rate = float64(port.Messages-previous) / elapsed
+		}
+		update.Ports = append(update.Ports, rate)
+	}
+	r.last, r.messages = now, messages
+	return update
+
+}
@@ -0,0 +1,79 @@
+package churnui
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PeekedValue is a single recent value of a port
+type PeekedValue struct {
+	Time time.Time `json:"time"`
+	// Value is the json encoding of the value, or a
+	// string describing it if it cannot be encoded
+	Value json.RawMessage `json:"value"`
+}
+
+// peek keeps the most recent values of a single port
+type peek struct {
+	cancel func()
+
+	mutex  sync.Mutex
+	values []PeekedValue
+	next   int
+}
+
+// peek returns the recent values of the given port. The first
+// peek at a port starts recording its values, and so returns none
+func (d *Dashboard) peek(portPath string) ([]PeekedValue, error) {
+
+	d.peekLock.Lock()
+	defer d.peekLock.Unlock()
+
+	p, ok := d.peeks[portPath]
+	if ok {
+		return p.recent(), nil
+	}
+
+	p = &peek{values: make([]PeekedValue, 0, d.options.PeekSize)}
+	cancel, err := d.graph.Observe(portPath, p.record)
+	if err != nil {
+		return nil, err
+	}
+	p.cancel = cancel
+	d.peeks[portPath] = p
+	return []PeekedValue{}, nil
+
+}
+
+// record keeps a value, replacing the oldest once full
+func (p *peek) record(value interface{}) {
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		encoded, _ = json.Marshal(fmt.Sprintf("%v", value))
+	}
+	v := PeekedValue{Time: time.Now(), Value: encoded}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if len(p.values) < cap(p.values) {
+		p.values = append(p.values, v)
+		return
+	}
+	p.values[p.next] = v
+	p.next = (p.next + 1) % len(p.values)
+
+}
+
+// recent returns the kept values from oldest to newest
+func (p *peek) recent() []PeekedValue {
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	recent := make([]PeekedValue, 0, len(p.values))
+	recent = append(recent, p.values[p.next:]...)
+	return append(recent, p.values[:p.next]...)
+
+}
@@ -0,0 +1,115 @@
+package churnui
+
+import (
+	"path"
+	"reflect"
+
+	"github.com/rydrman/churn"
+)
+
+// Topology describes the components and connections of a graph,
+// including those of all sub-graphs, by their full graph paths
+type Topology struct {
+	Components  []Component  `json:"components"`
+	Connections []Connection `json:"connections"`
+}
+
+// Component describes a single node or sub-graph
+type Component struct {
+	Path string `json:"path"`
+	// Kind is either "node" or "graph"
+	Kind   string `json:"kind"`
+	Type   string `json:"type"`
+	Paused bool   `json:"paused,omitempty"`
+	Ins    []Port `json:"ins,omitempty"`
+	Outs   []Port `json:"outs,omitempty"`
+}
+
+// Port describes a single in or out port of a node
+type Port struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Connection describes a single connection between ports
+type Connection struct {
+	Source     string   `json:"source"`
+	Dest       string   `json:"dest"`
+	Transforms []string `json:"transforms,omitempty"`
+	QueueSize  int      `json:"queueSize,omitempty"`
+	Delayed    bool     `json:"delayed,omitempty"`
+}
+
+// Describe returns the topology of the given graph
+func Describe(graph *churn.Graph) Topology {
+
+	var topology Topology
+	describe(graph, graph, "", &topology)
+	return topology
+
+}
+
+// describe adds the components and connections of 'sub' to the
+// topology, where 'prefix' is its path within 'root'
+func describe(root, sub *churn.Graph, prefix string, topology *Topology) {
+
+	for _, name := range sub.Components() {
+		cmptPath := path.Join(prefix, name)
+		cmpt := sub.GetComponent(name)
+		if nested, ok := cmpt.(*churn.Graph); ok {
+			topology.Components = append(topology.Components, Component{
+				Path: cmptPath,
+				Kind: "graph",
+				Type: typeName(cmpt),
+			})
+			describe(root, nested, cmptPath, topology)
+			continue
+		}
+		if _, ok := cmpt.(churn.Node); !ok {
+			continue
+		}
+		ports := sub.GetPorts(name)
+		c := Component{
+			Path:   cmptPath,
+			Kind:   "node",
+			Type:   typeName(cmpt),
+			Paused: root.Paused(cmptPath),
+		}
+		for _, port := range ports.Ins {
+			c.Ins = append(c.Ins, Port{port.Name, port.DataType().String()})
+		}
+		for _, port := range ports.Outs {
+			c.Outs = append(c.Outs, Port{port.Name, port.DataType().String()})
+		}
+		topology.Components = append(topology.Components, c)
+	}
+
+	for _, conn := range sub.Connections() {
+		topology.Connections = append(topology.Connections, Connection{
+			Source:     join(prefix, conn.Source),
+			Dest:       join(prefix, conn.Dest),
+			Transforms: conn.Transforms,
+			QueueSize:  conn.QueueSize,
+			Delayed:    conn.Delayed,
+		})
+	}
+
+}
+
+// join prefixes a port path with the path of its graph
+func join(prefix, portPath string) string {
+
+	location, node, port := churn.SplitGraphPath(portPath)
+	return churn.BuildGraphPath(path.Join(prefix, location), node, port)
+
+}
+
+func typeName(v interface{}) string {
+
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.String()
+
+}
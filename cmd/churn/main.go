@@ -0,0 +1,38 @@
+// Command churn provides tools for working with running churn graphs
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `usage: churn <command> [arguments]
+
+commands:
+  top    show a live table of the nodes in a running graph
+`
+
+func main() {
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "top":
+		err = runTop(os.Args[2:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "churn: unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "churn %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+
+}
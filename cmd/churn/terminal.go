@@ -0,0 +1,100 @@
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// The keys understood by top
+const (
+	keyUp    = "up"
+	keyDown  = "down"
+	keyEnter = "enter"
+	keyBack  = "back"
+	keySort  = "sort"
+	keyQuit  = "quit"
+)
+
+// rawTerminal switches the terminal to raw mode so that single key
+// presses can be read, returning a function that restores it
+func rawTerminal() (restore func(), err error) {
+
+	state, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, err
+	}
+	return func() { stty(state) }, nil
+
+}
+
+func stty(args ...string) (string, error) {
+
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+
+}
+
+// readKeys sends each key read from 'r' until it fails
+func readKeys(r io.Reader, keys chan<- string) {
+
+	buf := make([]byte, 16)
+	for {
+		n, err := r.Read(buf)
+		for _, key := range parseKeys(buf[:n]) {
+			keys <- key
+		}
+		if err != nil {
+			keys <- keyQuit
+			return
+		}
+	}
+
+}
+
+// parseKeys converts raw terminal input into keys, where arrow
+// keys and their vi equivalents are given names and any other
+// printable character is passed on as itself
+func parseKeys(input []byte) []string {
+
+	var keys []string
+	for i := 0; i < len(input); i++ {
+		c := input[i]
+		switch {
+		case c == 0x1b && i+2 < len(input) && input[i+1] == '[':
+			switch input[i+2] {
+			case 'A':
+				keys = append(keys, keyUp)
+			case 'B':
+				keys = append(keys, keyDown)
+			case 'C':
+				keys = append(keys, keyEnter)
+			case 'D':
+				keys = append(keys, keyBack)
+			}
+			i += 2
+		case c == 'k':
+			keys = append(keys, keyUp)
+		case c == 'j':
+			keys = append(keys, keyDown)
+		case c == '\r' || c == '\n' || c == 'l':
+			keys = append(keys, keyEnter)
+		case c == 0x7f || c == 0x08 || c == 'h':
+			keys = append(keys, keyBack)
+		case c == 's':
+			keys = append(keys, keySort)
+		case c == 'q' || c == 0x03:
+			keys = append(keys, keyQuit)
+		case c >= ' ' && c < 0x7f:
+			keys = append(keys, string(c))
+		}
+	}
+	return keys
+
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rydrman/churn"
+	"github.com/rydrman/churn/churnui"
+
+	"github.com/pkg/errors"
+)
+
+// sortKey is a column that the table can be sorted by
+type sortKey int
+
+// The columns that the table can be sorted by, in display order
+const (
+	byThroughput sortKey = iota
+	byLatency
+	byQueue
+	byErrors
+	byBlocked
+	numSortKeys
+)
+
+var sortNames = [numSortKeys]string{"throughput", "latency", "queue", "errors", "blocked"}
+
+// row is the recent activity of a single node, or
+// of all of the nodes within a sub-graph
+type row struct {
+	Name  string
+	Graph bool
+	// Throughput is the number of messages received per
+	// second, or sent for nodes that receive nothing
+	Throughput float64
+	// Latency is the mean handler latency since the last
+	// update, or zero if no handlers were called
+	Latency time.Duration
+	Queue   int
+	Errors  uint64
+	// Blocked is the fraction of time that the out
+	// ports have spent waiting on their connections
+	Blocked float64
+}
+
+// totals are the cumulative counters of a single row
+type totals struct {
+	received, sent, calls, errors uint64
+	latency, blocked              time.Duration
+	queue                         int
+}
+
+// top fetches and tabulates the activity of a running graph
+type top struct {
+	endpoint *url.URL
+	client   *http.Client
+
+	location string
+	sort     sortKey
+	selected int
+	rows     []row
+	updated  time.Time
+	err      error
+
+	// previous holds the totals of each row at the last
+	// update, by full graph path, to compute rates
+	previous map[string]totals
+}
+
+func runTop(args []string) error {
+
+	flags := flag.NewFlagSet("top", flag.ContinueOnError)
+	interval := flags.Duration("interval", time.Second, "time between updates")
+	sortBy := flags.String("sort", sortNames[byThroughput], "initial sort column: "+strings.Join(sortNames[:], ", "))
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: churn top [flags] [endpoint]")
+		fmt.Fprintln(flags.Output(), "\nendpoint is the url of a churnui dashboard, and defaults to http://localhost:8080/")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *interval <= 0 {
+		flags.Usage()
+		return errors.Errorf("invalid interval %s: must be positive", *interval)
+	}
+	initial := sortKey(-1)
+	for key, name := range sortNames {
+		if name == *sortBy {
+			initial = sortKey(key)
+		}
+	}
+	if initial < 0 {
+		flags.Usage()
+		return errors.Errorf("invalid sort %q: must be one of %s", *sortBy, strings.Join(sortNames[:], ", "))
+	}
+
+	endpoint := "http://localhost:8080/"
+	if flags.NArg() > 0 {
+		endpoint = flags.Arg(0)
+	}
+	t, err := newTop(endpoint)
+	if err != nil {
+		return err
+	}
+	t.sort = initial
+
+	restore, err := rawTerminal()
+	if err != nil {
+		return errors.Wrap(err, "cannot control the terminal")
+	}
+	defer restore()
+
+	keys := make(chan string)
+	go readKeys(os.Stdin, keys)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	t.update()
+	for {
+		t.render(os.Stdout)
+		select {
+		case <-ticker.C:
+			t.update()
+		case key := <-keys:
+			if key == keyQuit {
+				fmt.Print("\r\n")
+				return nil
+			}
+			if t.handleKey(key) {
+				t.update()
+			}
+		}
+	}
+
+}
+
+func newTop(endpoint string) (*top, error) {
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return &top{
+		endpoint: u,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		previous: make(map[string]totals),
+	}, nil
+
+}
+
+// update fetches the latest activity of the graph
+func (t *top) update() {
+
+	var (
+		topology churnui.Topology
+		stats    churn.Stats
+	)
+	t.err = t.get("api/graph", &topology)
+	if t.err == nil {
+		t.err = t.get("api/stats", &stats)
+	}
+	if t.err != nil {
+		return
+	}
+	t.tabulate(topology, stats, time.Now())
+
+}
+
+func (t *top) get(endpoint string, v interface{}) error {
+
+	resp, err := t.client.Get(t.endpoint.ResolveReference(&url.URL{Path: endpoint}).String())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("%s: %s", endpoint, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+
+}
+
+// tabulate builds the rows for the components at the current
+// location, comparing them to the previous update at 'now'
+func (t *top) tabulate(topology churnui.Topology, stats churn.Stats, now time.Time) {
+
+	current := make(map[string]*totals)
+	graphs := make(map[string]bool)
+	for _, c := range topology.Components {
+		name := t.child(c.Path)
+		if name != "" && path.Join(t.location, name) == c.Path {
+			current[name] = new(totals)
+			graphs[name] = c.Kind == "graph"
+		}
+	}
+	find := func(portPath string) *totals {
+		return current[t.child(owner(portPath))]
+	}
+
+	for _, port := range stats.Ports {
+		tot := find(port.Path)
+		switch {
+		case tot == nil:
+		case port.Direction == "in":
+			tot.received += port.Messages
+		default:
+			tot.sent += port.Messages
+		}
+	}
+	for _, h := range stats.Handlers {
+		if tot := find(h.Path); tot != nil {
+			tot.calls += h.Calls
+			tot.errors += h.Errors
+			tot.latency += h.Latency.Sum
+		}
+	}
+	for _, conn := range stats.Connections {
+		if tot := find(conn.Dest); tot != nil {
+			tot.queue += conn.QueueDepth
+		}
+		if tot := find(conn.Source); tot != nil {
+			tot.blocked += conn.Blocked
+		}
+	}
+
+	elapsed := now.Sub(t.updated).Seconds()
+	rows := make([]row, 0, len(current))
+	for name, tot := range current {
+		fullPath := path.Join(t.location, name)
+		prev, seen := t.previous[fullPath]
+		t.previous[fullPath] = *tot
+		r := row{Name: name, Graph: graphs[name], Queue: tot.queue, Errors: tot.errors}
+		if !seen || t.updated.IsZero() || elapsed <= 0 {
+			rows = append(rows, r)
+			continue
+		}
+		messages := delta(tot.received, prev.received)
+		if tot.received == 0 {
+			messages = delta(tot.sent, prev.sent)
+		}
+		r.Throughput = float64(messages) / elapsed
+		if calls := delta(tot.calls, prev.calls); calls > 0 && tot.latency >= prev.latency {
+			r.Latency = (tot.latency - prev.latency) / time.Duration(calls)
+		}
+		if tot.blocked >= prev.blocked {
+			r.Blocked = (tot.blocked - prev.blocked).Seconds() / elapsed
+		}
+		rows = append(rows, r)
+	}
+
+	t.rows = rows
+	t.updated = now
+	t.sortRows()
+
+}
+
+// delta returns the increase of a counter, or zero if it
+// was reset by its component being replaced
+func delta(current, previous uint64) uint64 {
+
+	if current < previous {
+		return 0
+	}
+	return current - previous
+
+}
+
+// child returns the name of the component at the current location
+// that contains the given path, or an empty string if there is none
+func (t *top) child(cmptPath string) string {
+
+	rel := cmptPath
+	if t.location != "" {
+		if !strings.HasPrefix(cmptPath, t.location+"/") {
+			return ""
+		}
+		rel = strings.TrimPrefix(cmptPath, t.location+"/")
+	}
+	return strings.SplitN(rel, "/", 2)[0]
+
+}
+
+// owner returns the path of the node that a port or handler belongs to
+func owner(portPath string) string {
+
+	location, node, _ := churn.SplitGraphPath(portPath)
+	return churn.BuildGraphPath(location, node, "")
+
+}
+
+func (t *top) sortRows() {
+
+	value := func(r row) float64 {
+		switch t.sort {
+		case byLatency:
+			return float64(r.Latency)
+		case byQueue:
+			return float64(r.Queue)
+		case byErrors:
+			return float64(r.Errors)
+		case byBlocked:
+			return r.Blocked
+		}
+		return r.Throughput
+	}
+	sort.SliceStable(t.rows, func(i, j int) bool {
+		a, b := value(t.rows[i]), value(t.rows[j])
+		if a != b {
+			return a > b
+		}
+		return t.rows[i].Name < t.rows[j].Name
+	})
+	if t.selected >= len(t.rows) {
+		t.selected = len(t.rows) - 1
+	}
+	if t.selected < 0 {
+		t.selected = 0
+	}
+
+}
+
+// handleKey applies a single key press, returning true
+// if the graph should be fetched again
+func (t *top) handleKey(key string) bool {
+
+	switch key {
+	case keyUp:
+		if t.selected > 0 {
+			t.selected--
+		}
+	case keyDown:
+		if t.selected < len(t.rows)-1 {
+			t.selected++
+		}
+	case keySort:
+		t.sort = (t.sort + 1) % numSortKeys
+		t.sortRows()
+	case keyEnter:
+		if t.selected < len(t.rows) && t.rows[t.selected].Graph {
+			t.location = path.Join(t.location, t.rows[t.selected].Name)
+			t.selected = 0
+			return true
+		}
+	case keyBack:
+		if t.location != "" {
+			t.location = strings.TrimSuffix(path.Dir(t.location), ".")
+			t.selected = 0
+			return true
+		}
+	default:
+		if len(key) == 1 && key[0] >= '1' && key[0] < '1'+byte(numSortKeys) {
+			t.sort = sortKey(key[0] - '1')
+			t.sortRows()
+		}
+	}
+	return false
+
+}
+
+// render draws the table for a terminal in raw mode
+func (t *top) render(w io.Writer) {
+
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	location := "/" + t.location
+	fmt.Fprintf(&b, "churn top  %s  graph: %s  sort: %s  %s\r\n\r\n",
+		t.endpoint, location, sortNames[t.sort], t.updated.Format("15:04:05"))
+	if t.err != nil {
+		fmt.Fprintf(&b, "error: %v\r\n\r\n", t.err)
+	}
+
+	fmt.Fprintf(&b, "  %-32s %12s %12s %8s %8s %9s\r\n",
+		"NAME", "1 MSG/S", "2 LATENCY", "3 QUEUE", "4 ERRORS", "5 BLOCKED")
+	for i, r := range t.rows {
+		name := r.Name
+		if r.Graph {
+			name += "/"
+		}
+		line := fmt.Sprintf("  %-32s %12.1f %12s %8d %8d %8.1f%%",
+			name, r.Throughput, formatLatency(r.Latency), r.Queue, r.Errors, r.Blocked*100)
+		if i == t.selected {
+			line = "\x1b[7m" + line + "\x1b[0m"
+		}
+		b.WriteString(line + "\r\n")
+	}
+
+	b.WriteString("\r\nup/down: select  enter: open sub-graph  backspace: parent  s, 1-5: sort  q: quit\r\n")
+	io.WriteString(w, b.String())
+
+}
+
+func formatLatency(d time.Duration) string {
+
+	if d == 0 {
+		return "-"
+	}
+	return d.Round(time.Microsecond).String()
+
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rydrman/churn"
+	"github.com/rydrman/churn/churnui"
+)
+
+type doubleNode struct{ churn.BaseNode }
+
+func (*doubleNode) InValue(v int64) (int64, error) { return v * 2, nil }
+
+func TestTop(t *testing.T) {
+
+	graph := churn.NewGraph()
+	source := new(churn.IntNode)
+	sub := churn.NewGraph()
+	graph.Add("Source", source)
+	graph.Add("Sub", sub)
+	sub.Add("Double", new(doubleNode))
+	sub.Add("Idle", new(doubleNode))
+	graph.Connect("Source.Value", "Sub/Double.Value")
+	defer graph.Close()
+
+	dashboard := churnui.New(graph, churnui.Options{})
+	defer dashboard.Close()
+	server := httptest.NewServer(dashboard)
+	defer server.Close()
+
+	top, err := newTop(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	top.update()
+	source.OutValue <- 1
+	source.OutValue <- 2
+	top.updated = top.updated.Add(-time.Second)
+	top.update()
+	if top.err != nil {
+		t.Fatal(top.err)
+	}
+
+	names := func() []string {
+		var names []string
+		for _, r := range top.rows {
+			names = append(names, r.Name)
+		}
+		return names
+	}
+	top.handleKey("2")
+	if !reflect.DeepEqual(names(), []string{"Sub", "Source"}) {
+		t.Fatalf("expected rows sorted by latency, got %v", names())
+	}
+	if !top.rows[0].Graph || top.rows[0].Throughput < 1 || top.rows[0].Latency == 0 {
+		t.Errorf("expected the sub-graph to aggregate its nodes, got %+v", top.rows[0])
+	}
+
+	if !top.handleKey(keyEnter) {
+		t.Fatal("expected entering a sub-graph to fetch it")
+	}
+	top.update()
+	if top.location != "Sub" || !reflect.DeepEqual(names(), []string{"Double", "Idle"}) {
+		t.Errorf("expected to navigate into the sub-graph, got %q %v", top.location, names())
+	}
+	top.handleKey(keyBack)
+	top.update()
+	if top.location != "" || len(top.rows) != 2 {
+		t.Errorf("expected to navigate back out, got %q %v", top.location, names())
+	}
+
+	buf := new(strings.Builder)
+	top.render(buf)
+	if !strings.Contains(buf.String(), "Sub/") {
+		t.Errorf("expected sub-graphs to be rendered, got %q", buf.String())
+	}
+
+}
+
+func TestParseKeys(t *testing.T) {
+
+	keys := parseKeys([]byte("j\x1b[A\r\x7f3q"))
+	expected := []string{keyDown, keyUp, keyEnter, keyBack, "3", keyQuit}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected %v, got %v", expected, keys)
+	}
+
+}
+
+func TestRunTop_Interval(t *testing.T) {
+
+	for _, interval := range []string{"0", "-1s"} {
+		if err := runTop([]string{"-interval", interval}); err == nil {
+			t.Errorf("%s: expected invalid interval error", interval)
+		}
+	}
+
+}
+
+func TestRunTop_Sort(t *testing.T) {
+
+	if err := runTop([]string{"-sort", "unknown"}); err == nil {
+		t.Error("expected invalid sort error")
+	}
+
+}
@@ -0,0 +1,137 @@
+package churn
+
+import (
+	"reflect"
+
+	"github.com/rydrman/churn/churncore"
+
+	"github.com/pkg/errors"
+)
+
+// Connection describes a link from an out port to an in port
+type Connection struct {
+	Source string
+	Dest   string
+
+	// Transforms describes each transform applied
+	// to messages in this connection, in order
+	Transforms []string
+
+	// QueueSize is the number of messages buffered by this
+	// connection, or zero if messages are delivered directly
+	// by the sending port
+	QueueSize int
+
+	// Delayed connections have an unbounded queue, and
+	// are allowed to complete a cycle in the graph
+	Delayed bool
+
+	transforms   []*churncore.Transform
+	subscription *churncore.Subscription
+}
+
+// ConnectOption is a type that applies one or more options
+// to a new connection between ports
+type ConnectOption interface {
+	Apply(*Connection) error
+}
+
+// ConnectOptionFunc is a function that can be given as a connect option
+type ConnectOptionFunc func(*Connection) error
+
+// Apply calls the underlying option function for c
+func (f ConnectOptionFunc) Apply(c *Connection) error { return f(c) }
+
+// Map transforms each message in a connection using 'mapFunc',
+// which must be of the form func(T) U. T must accept the messages
+// of the connection at this point, and U is passed on
+func Map(mapFunc interface{}) ConnectOption {
+	return ConnectOptionFunc(func(c *Connection) error {
+		t, err := churncore.NewMap(mapFunc)
+		if err != nil {
+			return err
+		}
+		c.addTransform(t)
+		return nil
+	})
+}
+
+// Filter drops each message in a connection for which 'predicate'
+// returns false. 'predicate' must be of the form func(T) bool
+func Filter(predicate interface{}) ConnectOption {
+	return ConnectOptionFunc(func(c *Connection) error {
+		t, err := churncore.NewFilter(predicate)
+		if err != nil {
+			return err
+		}
+		c.addTransform(t)
+		return nil
+	})
+}
+
+// Queue makes a connection deliver messages from its own goroutine,
+// buffering up to 'size' messages before blocking the out port. This
+// allows slow nodes to process messages in parallel with the rest of
+// the graph
+func Queue(size int) ConnectOption {
+	return ConnectOptionFunc(func(c *Connection) error {
+		c.QueueSize = size
+		return nil
+	})
+}
+
+// Delay makes a connection deliver messages from its own goroutine
+// through a queue that never blocks the out port. Every cycle of
+// connections in a graph must include a delayed connection, which
+// breaks the chain of handlers that would otherwise block on each
+// other. Messages do not carry their provenance across a delay
+func Delay() ConnectOption {
+	return ConnectOptionFunc(func(c *Connection) error {
+		c.Delayed = true
+		return nil
+	})
+}
+
+func (c *Connection) addTransform(t *churncore.Transform) {
+
+	c.transforms = append(c.transforms, t)
+	c.Transforms = append(c.Transforms, t.String())
+
+}
+
+// connect subscribes the receiver to the sender with all of the
+// options of this connection applied. If the message type does not
+// match the receiver after all transforms, 'conversion' is used to
+// find a transform that converts between them
+func (c *Connection) connect(
+	sender *churncore.Sender,
+	receiver *churncore.Receiver,
+	options []ConnectOption,
+	conversion func(from, to reflect.Type) *churncore.Transform,
+) error {
+
+	for _, option := range options {
+		if err := option.Apply(c); err != nil {
+			return errors.Wrapf(err, "%s -> %s", c.Source, c.Dest)
+		}
+	}
+
+	dataType := sender.DataType()
+	if len(c.transforms) > 0 {
+		dataType = c.transforms[len(c.transforms)-1].OutType()
+	}
+	if convert := conversion(dataType, receiver.DataType()); convert != nil {
+		c.addTransform(convert)
+	}
+
+	subs, err := sender.SubscribeWith(receiver, churncore.SubscribeOptions{
+		QueueSize: c.QueueSize,
+		Delay:     c.Delayed,
+	}, c.transforms...)
+	if err != nil {
+		return errors.Wrapf(err, "%s -> %s", c.Source, c.Dest)
+	}
+	c.subscription = subs
+	return nil
+
+}
@@ -0,0 +1,56 @@
+package churn
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestGraph_Connect_Transforms(t *testing.T) {
+
+	graph := NewGraph()
+	source := new(IntNode)
+	collector := &collectNode{values: make(chan string, 1)}
+	graph.Add("Source", source)
+	graph.Add("Collector", collector)
+	defer graph.Close()
+
+	err := graph.Connect(
+		"Source.Value", "Collector.Value",
+		Filter(func(v int64) bool { return v > 0 }),
+		Map(func(v int64) string { return strconv.FormatInt(v, 10) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source.OutValue <- -1
+	source.OutValue <- 1
+	if actual := <-collector.values; actual != "1" {
+		t.Errorf("expected only the transformed positive value, got %q", actual)
+	}
+
+	conns := graph.Connections()
+	expected := []string{"filter(func(int64) bool)", "map(func(int64) string)"}
+	if len(conns) != 1 || !reflect.DeepEqual(conns[0].Transforms, expected) {
+		t.Errorf("expected connection to describe its transforms, got %+v", conns)
+	}
+
+}
+
+func TestGraph_Connect_InvalidTransform(t *testing.T) {
+
+	graph := NewGraph()
+	graph.Add("Source", new(IntNode))
+	graph.Add("Collector", &collectNode{})
+	defer graph.Close()
+
+	err := graph.Connect("Source.Value", "Collector.Value", Map(strconv.Itoa))
+	if err == nil {
+		t.Error("expected map with mismatched input type to fail")
+	}
+	if len(graph.Connections()) != 0 {
+		t.Error("expected failed connection not to be recorded")
+	}
+
+}
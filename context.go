@@ -0,0 +1,76 @@
+package churn
+
+import (
+	"context"
+
+	"github.com/rydrman/churn/churncore"
+)
+
+type nodeKey struct{}
+
+// nodeRef identifies a node within a graph, and is resolved
+// lazily since a graph can be nested after nodes are added
+type nodeRef struct {
+	graph *Graph
+	name  string
+}
+
+func (r *nodeRef) path() string {
+	return BuildGraphPath(r.graph.Path(), r.name, "")
+}
+
+func (r *nodeRef) portPath(port string) string {
+	return BuildGraphPath(r.graph.Path(), r.name, port)
+}
+
+// Envelope carries the metadata of a single message
+type Envelope = churncore.Envelope
+
+// NodePath returns the graph path of the node whose in port
+// handler was given 'ctx', or an empty string if unknown
+func NodePath(ctx context.Context) string {
+
+	ref, ok := ctx.Value(nodeKey{}).(*nodeRef)
+	if !ok {
+		return ""
+	}
+	return ref.path()
+
+}
+
+// MessageEnvelope returns the envelope of the message being
+// handled with 'ctx', or nil if unknown
+func MessageEnvelope(ctx context.Context) *Envelope {
+	return churncore.EnvelopeFromContext(ctx)
+}
+
+// SetHeader sets a header on the messages that the node handling
+// 'ctx' sends until it receives its next message. Headers are
+// otherwise copied from the message most recently received
+func SetHeader(ctx context.Context, name, value string) {
+
+	lineage := churncore.LineageFromContext(ctx)
+	if lineage != nil {
+		lineage.SetHeader(name, value)
+	}
+
+}
+
+// CorrelationID returns the correlation id of the message
+// being handled with 'ctx', or an empty string if unknown
+func CorrelationID(ctx context.Context) string {
+
+	env := churncore.EnvelopeFromContext(ctx)
+	if env == nil {
+		return ""
+	}
+	return env.CorrelationID
+
+}
+
+// WithCorrelationID returns a context that gives the correlation id
+// 'id' to all messages sent with it from outside the graph, and so
+// to all of the messages derived from them
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return churncore.ContextWithEnvelope(ctx, &Envelope{CorrelationID: id})
+}
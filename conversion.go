@@ -0,0 +1,182 @@
+package churn
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/rydrman/churn/churncore"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	errorType    = reflect.TypeOf((*error)(nil)).Elem()
+	stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	bytesType    = reflect.TypeOf([]byte(nil))
+)
+
+// converter is a user registered conversion function
+type converter struct {
+	from     reflect.Type
+	to       reflect.Type
+	function reflect.Value
+}
+
+// RegisterConverter adds a conversion function that is used when
+// connecting ports of otherwise incompatible types. 'convertFunc'
+// must be of the form func(T) U or func(T) (U, error). Registered
+// converters take precedence over the built-in conversions. Panics
+// if 'convertFunc' is not of a valid form
+func RegisterConverter(convertFunc interface{}) GraphOption {
+
+	funcVal := reflect.ValueOf(convertFunc)
+	funcType := funcVal.Type()
+	valid := funcType.Kind() == reflect.Func && funcType.NumIn() == 1 &&
+		(funcType.NumOut() == 1 ||
+			funcType.NumOut() == 2 && funcType.Out(1) == errorType)
+	if !valid {
+		panic(errors.Wrapf(ErrInvalidConverter, "invalid type %T", convertFunc))
+	}
+
+	return OptionFunc(func(g *Graph) {
+		g.converters = append(g.converters, converter{
+			from:     funcType.In(0),
+			to:       funcType.Out(0),
+			function: funcVal,
+		})
+	})
+
+}
+
+// StrictTypes disables all automatic type conversion between
+// connected ports, including registered converters
+func StrictTypes() GraphOption {
+	return OptionFunc(func(g *Graph) {
+		g.strictTypes = true
+	})
+}
+
+// conversion finds a transform that converts messages from one
+// type to another, or returns nil if there is none
+func (g *Graph) conversion(from, to reflect.Type) *churncore.Transform {
+
+	if g.strictTypes || from.AssignableTo(to) {
+		return nil
+	}
+
+	for _, c := range g.converters {
+		if !from.AssignableTo(c.from) || !c.to.AssignableTo(to) {
+			continue
+		}
+		function := c.function
+		return churncore.NewConversion(from, to, func(val reflect.Value) (reflect.Value, error) {
+			out := function.Call([]reflect.Value{val})
+			if len(out) > 1 && !out[1].IsNil() {
+				return reflect.Value{}, out[1].Interface().(error)
+			}
+			return out[0], nil
+		})
+	}
+
+	convert := builtinConversion(from, to)
+	if convert == nil {
+		return nil
+	}
+	return churncore.NewConversion(from, to, convert)
+
+}
+
+// builtinConversion returns a conversion function for types that
+// churn knows how to convert between, or nil if there is none
+func builtinConversion(from, to reflect.Type) func(reflect.Value) (reflect.Value, error) {
+
+	switch {
+
+	case isNumeric(from) && isNumeric(to):
+		if !isWidening(from, to) {
+			return nil
+		}
+		return func(val reflect.Value) (reflect.Value, error) {
+			return val.Convert(to), nil
+		}
+
+	// named types that share the same underlying type
+	case from.Kind() == to.Kind() && from.ConvertibleTo(to):
+		return func(val reflect.Value) (reflect.Value, error) {
+			return val.Convert(to), nil
+		}
+
+	case from.Implements(stringerType) && to.Kind() == reflect.String:
+		return func(val reflect.Value) (reflect.Value, error) {
+			str := val.Interface().(fmt.Stringer).String()
+			return reflect.ValueOf(str).Convert(to), nil
+		}
+
+	case from == bytesType && isJSONObject(to):
+		return func(val reflect.Value) (reflect.Value, error) {
+			ptr := reflect.New(to)
+			err := json.Unmarshal(val.Bytes(), ptr.Interface())
+			return ptr.Elem(), err
+		}
+
+	case isJSONObject(from) && to == bytesType:
+		return func(val reflect.Value) (reflect.Value, error) {
+			data, err := json.Marshal(val.Interface())
+			return reflect.ValueOf(data), err
+		}
+
+	}
+
+	return nil
+
+}
+
+func isNumeric(t reflect.Type) bool {
+	return isInt(t) || isUint(t) || isFloat(t)
+}
+
+func isInt(t reflect.Type) bool {
+	return t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64
+}
+
+func isUint(t reflect.Type) bool {
+	return t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uintptr
+}
+
+func isFloat(t reflect.Type) bool {
+	return t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64
+}
+
+// isWidening returns true if every value of the numeric type
+// 'from' can be represented exactly in 'to'
+func isWidening(from, to reflect.Type) bool {
+
+	switch {
+	case isFloat(to) && isFloat(from):
+		return to.Size() >= from.Size()
+	case isFloat(to):
+		// integers must fit within the mantissa, which
+		// holds 53 bits in a float64 and 24 in a float32
+		return from.Size() <= to.Size()/2
+	case isFloat(from):
+		return false
+	case isInt(from) == isInt(to):
+		return to.Size() >= from.Size()
+	case isUint(from) && isInt(to):
+		return to.Size() > from.Size()
+	}
+	return false
+
+}
+
+// isJSONObject returns true for types that are
+// encoded as json objects
+func isJSONObject(t reflect.Type) bool {
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct || t.Kind() == reflect.Map
+
+}
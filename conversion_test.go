@@ -0,0 +1,158 @@
+package churn
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestBuiltinConversion(t *testing.T) {
+
+	type point struct{ X, Y int }
+	type celsius float64
+
+	cases := []struct {
+		from     interface{}
+		to       interface{}
+		expected interface{}
+	}{
+		{from: int32(1), to: float64(0), expected: float64(1)},
+		{from: int16(1), to: float32(0), expected: float32(1)},
+		{from: int64(1), to: float64(0), expected: nil},
+		{from: int32(1), to: float32(0), expected: nil},
+		{from: uint64(1), to: float32(0), expected: nil},
+		{from: float32(1), to: float64(0), expected: float64(1)},
+		{from: int8(1), to: int64(0), expected: int64(1)},
+		{from: uint16(1), to: int32(0), expected: int32(1)},
+		{from: int64(1), to: int8(0), expected: nil},
+		{from: float64(1), to: int64(0), expected: nil},
+		{from: uint64(1), to: int64(0), expected: nil},
+		{from: float64(1), to: celsius(0), expected: celsius(1)},
+		{from: time.Second, to: "", expected: "1s"},
+		{from: []byte(`{"X":1,"Y":2}`), to: point{}, expected: point{1, 2}},
+		{from: point{1, 2}, to: []byte(nil), expected: []byte(`{"X":1,"Y":2}`)},
+		{from: "string", to: 0, expected: nil},
+	}
+
+	for _, c := range cases {
+		from, to := reflect.TypeOf(c.from), reflect.TypeOf(c.to)
+		convert := builtinConversion(from, to)
+		if c.expected == nil {
+			if convert != nil {
+				t.Errorf("expected no conversion from %s to %s", from, to)
+			}
+			continue
+		}
+		if convert == nil {
+			t.Errorf("expected a conversion from %s to %s", from, to)
+			continue
+		}
+		actual, err := convert(reflect.ValueOf(c.from))
+		if err != nil {
+			t.Errorf("unexpected error converting %s to %s: %s", from, to, err)
+			continue
+		}
+		if !reflect.DeepEqual(actual.Interface(), c.expected) {
+			t.Errorf("expected %s to convert to %v, got %v", from, c.expected, actual)
+		}
+	}
+
+}
+
+func TestGraph_Connect_Conversion(t *testing.T) {
+
+	graph := NewGraph(RegisterConverter(strconv.Itoa))
+	source := &struct {
+		BaseNode
+		OutValue chan int32
+	}{}
+	ints := new(IntNode)
+	floats := &collectFloatNode{values: make(chan float64, 1)}
+	graph.Add("Source", source)
+	graph.Add("Collector", floats)
+	graph.Add("Ints", ints)
+	defer graph.Close()
+
+	if err := graph.Connect("Source.Value", "Collector.Value"); err != nil {
+		t.Fatal(err)
+	}
+
+	source.OutValue <- 1
+	if actual := <-floats.values; actual != 1 {
+		t.Errorf("expected int32 to be converted to float64, got %v", actual)
+	}
+
+	conns := graph.Connections()
+	if len(conns[0].Transforms) != 1 || conns[0].Transforms[0] != "convert(int32 -> float64)" {
+		t.Errorf("expected conversion to be described, got %v", conns[0].Transforms)
+	}
+
+	graph.Add("Strings", &collectNode{})
+	err := graph.Connect("Ints.Value", "Strings.Value", Map(func(v int64) int { return int(v) }))
+	if err != nil {
+		t.Errorf("expected registered converter to be used, got %s", err)
+	}
+
+}
+
+func TestGraph_Connect_StrictTypes(t *testing.T) {
+
+	graph := NewGraph(StrictTypes())
+	graph.Add("Source", new(IntNode))
+	graph.Add("Collector", &collectFloatNode{})
+	defer graph.Close()
+
+	err := graph.Connect("Source.Value", "Collector.Value")
+	if err == nil {
+		t.Error("expected strict graph not to convert between types")
+	}
+
+}
+
+func TestGraph_Connect_ConversionError(t *testing.T) {
+
+	errs := make(chan error, 1)
+	graph := NewGraph(ErrorHandler(func(err error) { errs <- err }))
+	source := &struct {
+		BaseNode
+		OutValue chan []byte
+	}{}
+	graph.Add("Source", source)
+	graph.Add("Collector", &pointNode{})
+	defer graph.Close()
+
+	if err := graph.Connect("Source.Value", "Collector.Value"); err != nil {
+		t.Fatal(err)
+	}
+
+	source.OutValue <- []byte("not json")
+	select {
+	case err := <-errs:
+		if _, ok := errors.Cause(err).(*json.SyntaxError); !ok {
+			t.Errorf("expected json syntax error from conversion, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for conversion error")
+	}
+
+}
+
+type pointNode struct{ BaseNode }
+
+func (*pointNode) InValue(struct{ X, Y int }) {}
+
+func TestRegisterConverter_Invalid(t *testing.T) {
+
+	defer func() {
+		err, _ := recover().(error)
+		if !IsInvalidConverter(err) {
+			t.Errorf("expected ErrInvalidConverter panic, got %v", err)
+		}
+	}()
+	RegisterConverter(func(a, b int) int { return 0 })
+
+}
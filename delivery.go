@@ -0,0 +1,68 @@
+package churn
+
+import (
+	"time"
+
+	"github.com/rydrman/churn/churncore"
+
+	"github.com/pkg/errors"
+)
+
+// Delivery decides which connections of an out port
+// receive each message sent through it
+type Delivery = churncore.Delivery
+
+// Broadcast delivers every message to every connection, and
+// is the default delivery mode of all out ports
+func Broadcast() Delivery { return churncore.Broadcast() }
+
+// RoundRobin delivers each message to a single connection,
+// taking turns between them in the order they were made
+func RoundRobin() Delivery { return churncore.RoundRobin() }
+
+// LeastLoaded delivers each message to the single connection with
+// the fewest queued and in-flight messages. It is best combined
+// with the Queue connect option
+func LeastLoaded() Delivery { return churncore.LeastLoaded() }
+
+// Partition delivers each message to a single connection chosen by
+// the key that 'keyFunc' returns for it, such that all messages with
+// the same key reach the same connection. 'keyFunc' must be of the
+// form func(T) K, where T accepts the messages of the out port
+func Partition(keyFunc interface{}) (Delivery, error) {
+	return churncore.Partition(keyFunc)
+}
+
+// SetDelivery changes how the messages of the out port at
+// the given graph path are distributed between its connections
+func (g *Graph) SetDelivery(portPath string, delivery Delivery) error {
+
+	port := g.GetOutPort(portPath)
+	if port == nil {
+		return errors.Wrap(ErrPortNotExist, portPath)
+	}
+
+	err := port.core.(*churncore.Sender).SetDelivery(delivery)
+	return errors.Wrap(err, portPath)
+
+}
+
+// SetReorder makes the in port at the given graph path handle messages
+// from each out port in the order they were sent, even if they arrive
+// out of order. At most 'window' messages are buffered per out port,
+// and a missing message is waited on for at most 'timeout'. A zero
+// window or timeout disables that limit. Since every message sent
+// after a connection is made must reach the port, it must be called
+// before the port is connected, and only out ports with Broadcast
+// delivery can then be connected to it
+func (g *Graph) SetReorder(portPath string, window int, timeout time.Duration) error {
+
+	port := g.GetInPort(portPath)
+	if port == nil {
+		return errors.Wrap(ErrPortNotExist, portPath)
+	}
+
+	err := port.core.(*churncore.Receiver).SetReorder(window, timeout)
+	return errors.Wrap(err, portPath)
+
+}
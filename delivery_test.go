@@ -0,0 +1,162 @@
+package churn
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rydrman/churn/churncore"
+)
+
+func TestGraph_SetDelivery(t *testing.T) {
+
+	graph := NewGraph()
+	source := new(StringNode)
+	first := &collectNode{values: make(chan string, 2)}
+	second := &collectNode{values: make(chan string, 2)}
+	graph.Add("Source", source)
+	graph.Add("First", first)
+	graph.Add("Second", second)
+	defer graph.Close()
+
+	err := graph.SetDelivery("Unknown.Value", RoundRobin())
+	if !IsPortNotExist(err) {
+		t.Errorf("expected ErrPortNotExist for unknown port, got %v", err)
+	}
+
+	if err = graph.SetDelivery("Source.Value", RoundRobin()); err != nil {
+		t.Fatal(err)
+	}
+	graph.Connect("Source.Value", "First.Value", Queue(1))
+	graph.Connect("Source.Value", "Second.Value", Queue(1))
+
+	source.OutValue <- "a"
+	source.OutValue <- "b"
+
+	for _, node := range []*collectNode{first, second} {
+		select {
+		case <-node.values:
+		case <-time.After(time.Second):
+			t.Fatal("expected each connection to receive one message")
+		}
+	}
+
+	if graph.Connections()[0].QueueSize != 1 {
+		t.Error("expected connection to describe its queue size")
+	}
+
+}
+
+func TestGraph_SetReorder(t *testing.T) {
+
+	graph := NewGraph()
+	source := new(StringNode)
+	collector := &collectNode{values: make(chan string, 3)}
+	graph.Add("Source", source)
+	graph.Add("Collector", collector)
+	defer graph.Close()
+
+	err := graph.SetReorder("Unknown.Value", 10, time.Second)
+	if !IsPortNotExist(err) {
+		t.Errorf("expected ErrPortNotExist for unknown port, got %v", err)
+	}
+
+	if err = graph.SetReorder("Collector.Value", 0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// messages sent before the connection is made are never
+	// waited on, even without a timeout
+	source.OutValue <- "dropped"
+	for graph.GetOutPort("Source.Value").core.(*churncore.Sender).Sent() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	graph.Connect("Source.Value", "Collector.Value", Queue(1))
+	source.OutValue <- "a"
+	select {
+	case actual := <-collector.values:
+		if actual != "a" {
+			t.Errorf("expected in order message to pass straight through, got %q", actual)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected message sent after connecting not to wait on earlier ones")
+	}
+
+	// later messages that arrive first are held back
+	// until the earlier ones have been handled
+	sender := churncore.NewDirectSender(reflect.TypeOf(""))
+	sender.SetSource(func() string { return "Other.Value" })
+	receiver := graph.GetInPort("Collector.Value").core.(*churncore.Receiver)
+	if _, err = sender.Subscribe(receiver); err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range []struct {
+		seq   uint64
+		value string
+	}{{2, "c"}, {1, "b"}, {3, "d"}} {
+		sender.Forward(&Envelope{Source: "Other.Value", Sequence: msg.seq}, reflect.ValueOf(msg.value))
+	}
+	for _, expected := range []string{"b", "c", "d"} {
+		if actual := <-collector.values; actual != expected {
+			t.Errorf("expected out of order messages to be reordered, got %q for %q", actual, expected)
+		}
+	}
+
+}
+
+func TestGraph_Deliver_Unsubscribes(t *testing.T) {
+
+	for name, options := range map[string][]GraphOption{
+		"Concurrent":    nil,
+		"Deterministic": {Deterministic()},
+	} {
+		t.Run(name, func(t *testing.T) {
+
+			graph := NewGraph(options...)
+			collector := &collectNode{values: make(chan string, 1)}
+			graph.Add("Collector", collector)
+			defer graph.Close()
+
+			if err := graph.Deliver("Collector.Value", "a"); err != nil {
+				t.Fatal(err)
+			}
+			graph.Settle()
+			if actual := <-collector.values; actual != "a" {
+				t.Errorf("expected delivered message, got %q", actual)
+			}
+
+			// the port can only be reordered if it was left unsubscribed
+			if err := graph.SetReorder("Collector.Value", 0, 0); err != nil {
+				t.Errorf("expected delivery not to leave a subscription, got %v", err)
+			}
+
+		})
+	}
+
+}
+
+func TestGraph_SetReorder_Invalid(t *testing.T) {
+
+	graph := NewGraph()
+	graph.Add("Source", new(StringNode))
+	graph.Add("Spread", new(StringNode))
+	graph.Add("Connected", &collectNode{})
+	graph.Add("Reordered", &collectNode{})
+	defer graph.Close()
+
+	graph.Connect("Source.Value", "Connected.Value")
+	if err := graph.SetReorder("Connected.Value", 0, time.Second); err == nil {
+		t.Error("expected a connected port not to be reordered")
+	}
+
+	graph.SetReorder("Reordered.Value", 0, time.Second)
+	graph.SetDelivery("Spread.Value", RoundRobin())
+	if err := graph.Connect("Spread.Value", "Reordered.Value"); err == nil {
+		t.Error("expected a round robin port not to connect to a reordered port")
+	}
+	graph.Connect("Source.Value", "Reordered.Value")
+	if err := graph.SetDelivery("Source.Value", RoundRobin()); err == nil {
+		t.Error("expected a port connected to a reordered port to keep broadcasting")
+	}
+
+}
@@ -1,11 +1,32 @@
 package churn
 
-import "github.com/pkg/errors"
+import (
+	"github.com/rydrman/churn/churncore"
+
+	"github.com/pkg/errors"
+)
 
 // Sentinal errors
 var (
 	ErrNameTaken    = errors.New("name is already in use")
 	ErrPortNotExist = errors.New("port does not exist")
+	ErrSendOnlyPort = errors.New("out port channel is send-only")
+	ErrRecvOnlyPort = errors.New("in port channel is receive-only")
+	ErrInvalidJoin  = errors.New("invalid join configuration")
+
+	ErrComponentNotExist = errors.New("component does not exist")
+	ErrNotConnected      = errors.New("ports are not connected")
+
+	ErrInvalidConverter = errors.New("invalid converter function")
+	ErrCycle            = errors.New("cycle without a delayed connection")
+	ErrStalled          = errors.New("ports are stalled")
+	ErrTypeMismatch     = errors.New("value does not match port type")
+	ErrClosed           = errors.New("graph is closed")
+	ErrNoOutputs        = errors.New("graph has no outputs")
+
+	// ErrPanic is the cause of the errors reported
+	// for in port handlers that panic
+	ErrPanic = churncore.ErrPanic
 )
 
 // IsNameTaken returns true if the given error derives from
@@ -20,6 +41,78 @@ func IsPortNotExist(err error) bool {
 	return errors.Cause(err) == ErrPortNotExist
 }
 
+// IsSendOnlyPort returns true if the given error derives from
+// a node providing an out port channel that cannot be read
+func IsSendOnlyPort(err error) bool {
+	return errors.Cause(err) == ErrSendOnlyPort
+}
+
+// IsRecvOnlyPort returns true if the given error derives from
+// a node providing an in port channel that cannot be sent into
+func IsRecvOnlyPort(err error) bool {
+	return errors.Cause(err) == ErrRecvOnlyPort
+}
+
+// IsInvalidJoin returns true if the given error derives from
+// a node describing a join that does not match its in port
+func IsInvalidJoin(err error) bool {
+	return errors.Cause(err) == ErrInvalidJoin
+}
+
+// IsInvalidConverter returns true if the given error derives
+// from registering a conversion function of an invalid form
+func IsInvalidConverter(err error) bool {
+	return errors.Cause(err) == ErrInvalidConverter
+}
+
+// IsCycle returns true if the given error derives from a
+// cycle of connections that could block indefinitely
+func IsCycle(err error) bool {
+	return errors.Cause(err) == ErrCycle
+}
+
+// IsStalled returns true if the given error is a watchdog
+// report of blocked ports
+func IsStalled(err error) bool {
+	return errors.Cause(err) == ErrStalled
+}
+
+// IsTypeMismatch returns true if the given error derives
+// from a value that cannot be given to a port
+func IsTypeMismatch(err error) bool {
+	return errors.Cause(err) == ErrTypeMismatch
+}
+
+// IsClosed returns true if the given error derives
+// from sending to a graph that has been closed
+func IsClosed(err error) bool {
+	return errors.Cause(err) == ErrClosed
+}
+
+// IsNoOutputs returns true if the given error derives
+// from calling a graph that has no outputs
+func IsNoOutputs(err error) bool {
+	return errors.Cause(err) == ErrNoOutputs
+}
+
+// IsPanic returns true if the given error derives
+// from an in port handler that panicked
+func IsPanic(err error) bool {
+	return errors.Cause(err) == ErrPanic
+}
+
+// IsComponentNotExist returns true if the given error
+// derives from a component not existing
+func IsComponentNotExist(err error) bool {
+	return errors.Cause(err) == ErrComponentNotExist
+}
+
+// IsNotConnected returns true if the given error derives
+// from removing a connection that does not exist
+func IsNotConnected(err error) bool {
+	return errors.Cause(err) == ErrNotConnected
+}
+
 func panicIfError(err error) {
 	if err != nil {
 		panic(err)
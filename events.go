@@ -0,0 +1,117 @@
+package churn
+
+// Event describes a change to a graph or one of its sub-graphs.
+// All paths in events are full graph paths from the outermost graph
+type Event interface {
+	event()
+}
+
+// ComponentAdded is emitted once a component has been added
+// to a graph, and any node has been initialized
+type ComponentAdded struct {
+	Path      string
+	Component Component
+}
+
+// ComponentRemoved is emitted once a component has been removed
+// from a graph, after all of its connections were disconnected
+type ComponentRemoved struct {
+	Path string
+}
+
+// Connected is emitted when an out port is connected to an in port
+type Connected struct {
+	Source string
+	Dest   string
+}
+
+// Disconnected is emitted when a connection is removed
+type Disconnected struct {
+	Source string
+	Dest   string
+}
+
+// NodeStarted is emitted once a node is ready to handle messages,
+// which for a Runner node is before the graph is started
+type NodeStarted struct {
+	Path string
+}
+
+// NodeStopped is emitted when the Run function of a node returns, or
+// when a node that is not running is removed or its graph is closed
+type NodeStopped struct {
+	Path string
+}
+
+// NodeErrored is emitted for each error returned by a node, either
+// from an in port handler or from its Run function
+type NodeErrored struct {
+	Path string
+	Err  error
+}
+
+// ParamsChanged is emitted when a node reports that
+// the values of its parameters have changed
+type ParamsChanged struct {
+	Path string
+}
+
+func (ComponentAdded) event()   {}
+func (ComponentRemoved) event() {}
+func (Connected) event()        {}
+func (Disconnected) event()     {}
+func (NodeStarted) event()      {}
+func (NodeStopped) event()      {}
+func (NodeErrored) event()      {}
+func (ParamsChanged) event()    {}
+
+// Subscribe calls 'subscriber' with every event emitted by this graph
+// and its sub-graphs until the returned cancel function is called.
+// Events are delivered from the goroutine that caused them, in order
+// for any single goroutine, and may be delivered concurrently
+func (g *Graph) Subscribe(subscriber func(Event)) (cancel func()) {
+
+	sub := &subscriber
+	g.eventLock.Lock()
+	g.subscribers = append(g.subscribers, sub)
+	g.eventLock.Unlock()
+
+	return func() {
+		g.eventLock.Lock()
+		defer g.eventLock.Unlock()
+		for i, s := range g.subscribers {
+			if s == sub {
+				// copy so that in-flight events keep a consistent view
+				g.subscribers = append(g.subscribers[:i:i], g.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+
+}
+
+// NotifyParamsChanged emits a ParamsChanged event for this node, and
+// should be called by nodes whose configuration changes while running
+func (n *BaseNode) NotifyParamsChanged() {
+
+	if n.ref != nil {
+		n.ref.graph.emit(ParamsChanged{Path: n.ref.path()})
+	}
+
+}
+
+// emit passes an event to the subscribers of this
+// graph and of every graph that it is nested in
+func (g *Graph) emit(event Event) {
+
+	for ; g != nil; g = g.parent {
+		g.eventLock.Lock()
+		subscribers := g.subscribers
+		g.eventLock.Unlock()
+
+		for _, subscriber := range subscribers {
+			(*subscriber)(event)
+		}
+	}
+
+}
@@ -0,0 +1,114 @@
+package churn
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+type failNode struct{ BaseNode }
+
+func (*failNode) InValue(s string) error { return errors.New(s) }
+
+func (n *failNode) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+type eventRecorder struct {
+	mutex  sync.Mutex
+	events []Event
+}
+
+func (r *eventRecorder) record(e Event) {
+	r.mutex.Lock()
+	r.events = append(r.events, e)
+	r.mutex.Unlock()
+}
+
+func (r *eventRecorder) take() []Event {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	events := r.events
+	r.events = nil
+	return events
+}
+
+func TestGraph_Subscribe(t *testing.T) {
+
+	events := new(eventRecorder)
+	graph := NewGraph(ErrorHandler(func(error) {}))
+	cancel := graph.Subscribe(events.record)
+	sub := NewGraph(Deterministic())
+	source := new(StringNode)
+	graph.Add("Sub", sub)
+	sub.Add("Source", source)
+	sub.Add("Fail", new(failNode))
+	graph.Connect("Sub/Source.Value", "Sub/Fail.Value")
+	source.OutValue <- "failed"
+	sub.Settle()
+
+	actual := events.take()
+	for i, e := range actual {
+		if added, ok := e.(ComponentAdded); ok {
+			added.Component = nil
+			actual[i] = added
+		}
+		if errored, ok := e.(NodeErrored); ok {
+			if errored.Err == nil {
+				t.Error("expected NodeErrored to carry the error")
+			}
+			errored.Err = nil
+			actual[i] = errored
+		}
+	}
+	expected := []Event{
+		ComponentAdded{Path: "Sub"},
+		ComponentAdded{Path: "Sub/Source"},
+		NodeStarted{Path: "Sub/Source"},
+		ComponentAdded{Path: "Sub/Fail"},
+		NodeStarted{Path: "Sub/Fail"},
+		Connected{Source: "Sub/Source.Value", Dest: "Sub/Fail.Value"},
+		NodeErrored{Path: "Sub/Fail"},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected events %v, got %v", expected, actual)
+	}
+
+	if err := sub.Remove("Missing"); !IsComponentNotExist(err) {
+		t.Errorf("expected ErrComponentNotExist, got %v", err)
+	}
+	if err := sub.Remove("Fail"); err != nil {
+		t.Fatal(err)
+	}
+	expected = []Event{
+		Disconnected{Source: "Sub/Source.Value", Dest: "Sub/Fail.Value"},
+		NodeStopped{Path: "Sub/Fail"},
+		ComponentRemoved{Path: "Sub/Fail"},
+	}
+	if actual := events.take(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected events %v, got %v", expected, actual)
+	}
+	if len(graph.Connections()) != 0 {
+		t.Error("expected the connection to the removed node to be closed")
+	}
+
+	source.NotifyParamsChanged()
+	if actual := events.take(); !reflect.DeepEqual(actual, []Event{ParamsChanged{Path: "Sub/Source"}}) {
+		t.Errorf("expected a ParamsChanged event, got %v", actual)
+	}
+
+	if err := graph.Disconnect("Sub/Source.Value", "Sub/Fail.Value"); !IsNotConnected(err) {
+		t.Errorf("expected ErrNotConnected, got %v", err)
+	}
+
+	cancel()
+	graph.Close()
+	if actual := events.take(); len(actual) != 0 {
+		t.Errorf("expected no events after cancelling, got %v", actual)
+	}
+
+}
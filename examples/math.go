@@ -1,5 +1,52 @@
 package main
 
+import (
+	"time"
+
+	"github.com/rydrman/churn"
+)
+
+// AddNode outputs the sum of each pair of operands
+type AddNode struct {
+	churn.BaseNode
+}
+
+// InSum adds its two operands together
+func (*AddNode) InSum(a, b float64) (float64, error) {
+
+	return a + b, nil
+
+}
+
+// Joins names the operands of InSum
+func (*AddNode) Joins() map[string]churn.Join {
+
+	return map[string]churn.Join{
+		"Sum": {Args: []string{"a", "b"}, Policy: churn.JoinZip},
+	}
+
+}
+
 func main() {
 
+	graph := churn.NewGraph()
+	defer graph.Close()
+
+	a, b := new(churn.FloatNode), new(churn.FloatNode)
+	graph.Add("A", a)
+	graph.Add("B", b)
+	graph.Add("Add", new(AddNode))
+	graph.Add("Printer", new(churn.PrintNode))
+
+	graph.Connect("A.Value", "Add.Sum:a")
+	graph.Connect("B.Value", "Add.Sum:b")
+	graph.Connect("Add.SumResult", "Printer.Message")
+
+	a.OutValue <- 1
+	b.OutValue <- 2
+	a.OutValue <- 10
+	b.OutValue <- 20
+
+	time.Sleep(time.Millisecond) // allow the signals to propagate
+
 }
@@ -2,10 +2,14 @@
 package churn
 
 import (
+	"context"
+	"log/slog"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/rydrman/churn/churncore"
 
@@ -13,8 +17,13 @@ import (
 )
 
 const (
-	inPortNamePrefix  = "In"
-	outPortNamePrefix = "Out"
+	inPortNamePrefix     = "In"
+	outPortNamePrefix    = "Out"
+	resultPortNameSuffix = "Result"
+
+	// resultTagName is the struct tag that directs the results of
+	// a functional in port to an out port, eg: `result:"Name"`
+	resultTagName = "result"
 )
 
 // Graph constructs and manages connections
@@ -22,20 +31,76 @@ const (
 type Graph struct {
 	BaseComponent
 	components     map[string]Component
+	connections    []*Connection
 	componentMutex sync.Mutex
 
+	// parent and name locate this graph when it
+	// is nested within another
+	parent *Graph
+	name   string
+
 	channelBufferSize int
+	handlerTimeout    time.Duration
+	onError           func(error)
+	converters        []converter
+	strictTypes       bool
+	watchdog          time.Duration
+	onStall           func(*StallReport)
+	scheduler         *churncore.Scheduler
+	exporter          SpanExporter
+	logHandler        slog.Handler
+	logLevel          slog.Leveler
+
+	// inlets feed messages sent from outside the graph into
+	// the in ports of its nodes, until the graph is closed
+	inlets       map[*Port]*inlet
+	inletsClosed bool
+	inletLock    sync.Mutex
+
+	// inputs and outputs are the ports used by Call, and
+	// calls are those waiting for their outputs by correlation id
+	inputs   map[string]string
+	outputs  map[string]*graphOutput
+	calls    map[string]*pendingCall
+	callLock sync.Mutex
+
+	// subscribers are given the events of this graph
+	// and its sub-graphs
+	subscribers []*func(Event)
+	eventLock   sync.Mutex
+
+	// ctx is cancelled when the graph is stopped, ending
+	// all running nodes which are tracked by runners, and
+	// stops cancel and wait for each node individually
+	stops   map[string]func()
+	ctx     context.Context
+	cancel  context.CancelFunc
+	runners sync.WaitGroup
+	runErr  error
+	errLock sync.Mutex
+
+	// starting is closed by the next call to Start, and
+	// Runner nodes wait on it before they are run
+	starting chan struct{}
 }
 
 // NewGraph initializes a new Graph instance
 func NewGraph(options ...GraphOption) *Graph {
 
+	ctx, cancel := context.WithCancel(context.Background())
 	g := &Graph{
 		components: make(map[string]Component),
+		stops:      make(map[string]func()),
+		starting:   make(chan struct{}),
+		ctx:        ctx,
+		cancel:     cancel,
 	}
 	for _, option := range options {
 		option.Apply(g)
 	}
+	if g.watchdog > 0 {
+		go g.watch()
+	}
 	return g
 
 }
@@ -56,9 +121,44 @@ func (g *Graph) SafeAdd(desiredName string, cmpt Component) (name string) {
 
 }
 
-// Add adds a node to this graph, where 'name' is expected to be unique
+// Add adds a node to this graph, where 'name' is expected to be unique.
+// Nodes are initialized as they are added, and any Runner nodes are
+// run by the next call to Start
 func (g *Graph) Add(name string, cmpt Component) error {
 
+	ctx, cancel := context.WithCancel(g.ctx)
+	if err := g.add(ctx, name, cmpt); err != nil {
+		cancel()
+		return err
+	}
+	g.log().Debug("component added", "name", name)
+	cmptPath := BuildGraphPath(g.Path(), name, "")
+	g.emit(ComponentAdded{Path: cmptPath, Component: cmpt})
+
+	stop := cancel
+	runner, isRunner := cmpt.(Runner)
+	if isRunner {
+		done := g.run(ctx, name, runner, g.nextStart())
+		stop = func() {
+			cancel()
+			<-done
+		}
+	}
+	g.componentMutex.Lock()
+	g.stops[name] = stop
+	g.componentMutex.Unlock()
+
+	if _, isNode := cmpt.(Node); isNode {
+		g.emit(NodeStarted{Path: cmptPath})
+	}
+	return nil
+
+}
+
+// add initializes and records a new component, where 'ctx'
+// is the parent context of the handlers of a node
+func (g *Graph) add(ctx context.Context, name string, cmpt Component) error {
+
 	g.componentMutex.Lock()
 	defer g.componentMutex.Unlock()
 
@@ -69,7 +169,22 @@ func (g *Graph) Add(name string, cmpt Component) error {
 
 	node, isNode := cmpt.(Node)
 	if isNode {
-		node.setupBaseNode(node)
+		err := node.setupBaseNode(node, g, name)
+		if err != nil {
+			return errors.Wrap(err, name)
+		}
+		g.bindNode(ctx, name, node)
+		node.Init()
+		g.log().Debug("node initialized", "name", name)
+	}
+
+	subGraph, isGraph := cmpt.(*Graph)
+	if isGraph {
+		// the watchdog of the sub-graph may be checking its parent
+		subGraph.componentMutex.Lock()
+		subGraph.parent = g
+		subGraph.name = name
+		subGraph.componentMutex.Unlock()
 	}
 
 	g.components[name] = cmpt
@@ -77,8 +192,171 @@ func (g *Graph) Add(name string, cmpt Component) error {
 
 }
 
+// Remove stops and closes the named component, after disconnecting
+// every connection to or from it that was made in this graph or
+// in any graph that this one is nested in. Handlers that are still
+// running are waited on before the out ports of a node are closed
+func (g *Graph) Remove(name string) error {
+
+	g.componentMutex.Lock()
+	cmpt, exists := g.components[name]
+	stop := g.stops[name]
+	delete(g.components, name)
+	delete(g.stops, name)
+	g.componentMutex.Unlock()
+	if !exists {
+		return errors.Wrap(ErrComponentNotExist, name)
+	}
+
+	g.disconnectAll(name)
+	if stop != nil {
+		stop()
+	}
+	cmpt.close()
+	g.log().Debug("component removed", "name", name)
+
+	cmptPath := BuildGraphPath(g.Path(), name, "")
+	if _, isRunner := cmpt.(Runner); !isRunner {
+		if _, isNode := cmpt.(Node); isNode {
+			g.emit(NodeStopped{Path: cmptPath})
+		}
+	}
+	g.emit(ComponentRemoved{Path: cmptPath})
+	return nil
+
+}
+
+// bindNode connects the in port handlers of a node to this
+// graph, where 'ctx' is the parent context of all handlers
+func (g *Graph) bindNode(ctx context.Context, name string, node Node) {
+
+	ref := &nodeRef{graph: g, name: name}
+	ctx = context.WithValue(ctx, nodeKey{}, ref)
+	lineage := new(churncore.Lineage)
+	for _, port := range node.catalog().Ins {
+		receiver, ok := port.core.(*churncore.Receiver)
+		if !ok {
+			continue
+		}
+		portName := handlerName(port.Name)
+		receiver.SetContext(ctx)
+		receiver.SetTimeout(g.handlerTimeout)
+		receiver.SetLineage(lineage)
+		receiver.SetTracer(g.tracer(ref, portName))
+		receiver.SetErrorHandler(func(err error) {
+			err = errors.Wrap(err, ref.portPath(portName))
+			g.emit(NodeErrored{Path: ref.path(), Err: err})
+			g.reportError(err)
+		})
+	}
+	for _, port := range node.catalog().Outs {
+		sender, ok := port.core.(*churncore.Sender)
+		if !ok {
+			continue
+		}
+		portName := port.Name
+		sender.SetScheduler(g.scheduler)
+		sender.SetLineage(lineage)
+		sender.SetSource(func() string { return ref.portPath(portName) })
+	}
+
+}
+
+// reportError passes an error to the handler of the closest
+// graph that has one, starting with this one, and otherwise logs it
+func (g *Graph) reportError(err error) {
+
+	for handler := g; handler != nil; handler = handler.parent {
+		if handler.onError != nil {
+			g.log().Debug("error reported", "error", err)
+			handler.onError(err)
+			return
+		}
+	}
+	// errors are otherwise lost, so they are always logged
+	g.log().Error("unhandled error", "error", err)
+
+}
+
+// run starts a running node in its own goroutine, which is
+// given 'ctx' and joined when the graph is stopped. The returned
+// channel is closed once the node has stopped running
+func (g *Graph) run(
+	ctx context.Context,
+	name string,
+	runner Runner,
+	start <-chan struct{},
+) <-chan struct{} {
+
+	done := make(chan struct{})
+	g.runners.Add(1)
+	go func() {
+		defer g.runners.Done()
+		defer close(done)
+		// a runner that was started is always run,
+		// even if it has been stopped since
+		var err error
+		select {
+		case <-start:
+			err = runner.Run(ctx)
+		case <-ctx.Done():
+			select {
+			case <-start:
+				err = runner.Run(ctx)
+			default:
+			}
+		}
+		nodePath := BuildGraphPath(g.Path(), name, "")
+		defer g.emit(NodeStopped{Path: nodePath})
+		if err == nil || errors.Cause(err) == context.Canceled {
+			return
+		}
+		g.log().Debug("node stopped with error", "name", name, "error", err)
+		g.emit(NodeErrored{Path: nodePath, Err: err})
+		g.errLock.Lock()
+		if g.runErr == nil {
+			g.runErr = errors.Wrap(err, name)
+		}
+		g.errLock.Unlock()
+	}()
+	return done
+
+}
+
+// Start runs every Runner node in this graph and its sub-graphs that
+// is waiting to be run. Runners are not run as they are added, so that
+// nothing they send is lost before their ports are connected, and
+// those added after Start only run once it is called again
+func (g *Graph) Start() {
+
+	g.componentMutex.Lock()
+	close(g.starting)
+	g.starting = make(chan struct{})
+	var subGraphs []*Graph
+	for _, cmpt := range g.components {
+		if subGraph, ok := cmpt.(*Graph); ok {
+			subGraphs = append(subGraphs, subGraph)
+		}
+	}
+	g.componentMutex.Unlock()
+
+	for _, subGraph := range subGraphs {
+		subGraph.Start()
+	}
+
+}
+
+// nextStart returns the channel that is closed by the next call to Start
+func (g *Graph) nextStart() <-chan struct{} {
+
+	g.componentMutex.Lock()
+	defer g.componentMutex.Unlock()
+	return g.starting
+
+}
+
 // Connect joins an out port on one node to the in port of another
-func (g *Graph) Connect(sourcePortPath, destPortPath string) error {
+func (g *Graph) Connect(sourcePortPath, destPortPath string, options ...ConnectOption) error {
 
 	srcPort := g.GetOutPort(sourcePortPath)
 	if srcPort == nil {
@@ -92,9 +370,132 @@ func (g *Graph) Connect(sourcePortPath, destPortPath string) error {
 	sender := srcPort.core.(*churncore.Sender)
 	receiver := destPort.core.(*churncore.Receiver)
 
-	_, err := sender.Subscribe(receiver)
-	// TODO: store the subscription for cleanup
-	return err
+	conn := &Connection{Source: sourcePortPath, Dest: destPortPath}
+	err := conn.connect(sender, receiver, options, g.conversion)
+	if err != nil {
+		return err
+	}
+
+	g.componentMutex.Lock()
+	g.connections = append(g.connections, conn)
+	g.componentMutex.Unlock()
+	g.log().Debug("ports connected", "source", sourcePortPath, "dest", destPortPath)
+	prefix := g.Path()
+	g.emit(Connected{
+		Source: fullPortPath(prefix, sourcePortPath),
+		Dest:   fullPortPath(prefix, destPortPath),
+	})
+	return nil
+
+}
+
+// Disconnect removes a connection that was made in this graph,
+// where the port paths are those given when it was connected
+func (g *Graph) Disconnect(sourcePortPath, destPortPath string) error {
+
+	removed := g.disconnect(func(conn *Connection) bool {
+		return conn.Source == sourcePortPath && conn.Dest == destPortPath
+	})
+	if removed == 0 {
+		return errors.Wrapf(ErrNotConnected, "%s -> %s", sourcePortPath, destPortPath)
+	}
+	return nil
+
+}
+
+// disconnectAll removes every connection to or from the named
+// component, including those made in the graphs that this one
+// is nested in
+func (g *Graph) disconnectAll(name string) {
+
+	cmptPath := name
+	for graph := g; graph != nil; graph = graph.parent {
+		graph.disconnect(func(conn *Connection) bool {
+			return isWithin(conn.Source, cmptPath) || isWithin(conn.Dest, cmptPath)
+		})
+		cmptPath = path.Join(graph.name, cmptPath)
+	}
+
+}
+
+// disconnect closes and removes all connections in this graph
+// that match, returning the number that were removed
+func (g *Graph) disconnect(match func(*Connection) bool) int {
+
+	g.componentMutex.Lock()
+	var removed, kept []*Connection
+	for _, conn := range g.connections {
+		if match(conn) {
+			removed = append(removed, conn)
+		} else {
+			kept = append(kept, conn)
+		}
+	}
+	g.connections = kept
+	g.componentMutex.Unlock()
+
+	prefix := g.Path()
+	for _, conn := range removed {
+		conn.subscription.Close()
+		g.log().Debug("ports disconnected", "source", conn.Source, "dest", conn.Dest)
+		g.emit(Disconnected{
+			Source: fullPortPath(prefix, conn.Source),
+			Dest:   fullPortPath(prefix, conn.Dest),
+		})
+	}
+	return len(removed)
+
+}
+
+// isWithin returns true if the given port path
+// belongs to the component at 'cmptPath'
+func isWithin(portPath, cmptPath string) bool {
+
+	location, node, _ := SplitGraphPath(portPath)
+	nodePath := BuildGraphPath(location, node, "")
+	return nodePath == cmptPath || strings.HasPrefix(nodePath, cmptPath+"/")
+
+}
+
+// Connections returns a description of every connection
+// that has been made in this graph
+func (g *Graph) Connections() []Connection {
+
+	g.componentMutex.Lock()
+	defer g.componentMutex.Unlock()
+
+	conns := make([]Connection, 0, len(g.connections))
+	for _, conn := range g.connections {
+		conns = append(conns, *conn)
+	}
+	return conns
+
+}
+
+// Components returns the names of all components
+// in this graph, in sorted order
+func (g *Graph) Components() []string {
+
+	g.componentMutex.Lock()
+	defer g.componentMutex.Unlock()
+
+	names := make([]string, 0, len(g.components))
+	for name := range g.components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+
+}
+
+// Path returns the graph path of this graph within its
+// outermost parent, or an empty string if it is not nested
+func (g *Graph) Path() string {
+
+	if g.parent == nil {
+		return ""
+	}
+	return BuildGraphPath(g.parent.Path(), g.name, "")
 
 }
 
@@ -154,6 +555,8 @@ func (g *Graph) GetComponent(cmptPath string) Component {
 
 	location, name, port := SplitGraphPath(cmptPath)
 	if location == "." {
+		g.componentMutex.Lock()
+		defer g.componentMutex.Unlock()
 		return g.components[name]
 	}
 
@@ -168,16 +571,74 @@ func (g *Graph) GetComponent(cmptPath string) Component {
 
 }
 
+// Stop cancels the context of all running nodes in this graph
+// and any sub-graphs, waiting for them to return. The first error
+// returned by a running node, if any, is returned
+func (g *Graph) Stop() error {
+
+	g.cancel()
+
+	g.componentMutex.Lock()
+	var subGraphs []*Graph
+	for _, cmpt := range g.components {
+		if subGraph, ok := cmpt.(*Graph); ok {
+			subGraphs = append(subGraphs, subGraph)
+		}
+	}
+	g.componentMutex.Unlock()
+
+	var err error
+	for _, subGraph := range subGraphs {
+		if subErr := subGraph.Stop(); err == nil {
+			err = subErr
+		}
+	}
+
+	g.runners.Wait()
+
+	g.errLock.Lock()
+	defer g.errLock.Unlock()
+	if g.runErr != nil {
+		return g.runErr
+	}
+	return err
+
+}
+
 // Close ends all node execution and tears down the graph node network
 func (g *Graph) Close() {
 
-	for _, cmpt := range g.components {
+	g.Stop()
+	g.removeOutputs()
+
+	g.componentMutex.Lock()
+	connections := append([]*Connection(nil), g.connections...)
+	components := make(map[string]Component, len(g.components))
+	for name, cmpt := range g.components {
+		components[name] = cmpt
+	}
+	g.componentMutex.Unlock()
+
+	for _, conn := range connections {
+		conn.subscription.Close()
+	}
+	g.closeInlets()
+	for name, cmpt := range components {
 		cmpt.close()
+		g.log().Debug("component closed", "name", name)
+		if _, isRunner := cmpt.(Runner); isRunner {
+			continue
+		}
+		if _, isNode := cmpt.(Node); isNode {
+			g.emit(NodeStopped{Path: BuildGraphPath(g.Path(), name, "")})
+		}
 	}
-	return
+	g.log().Debug("graph closed")
 
 }
 
+func (g *Graph) close() { g.Close() }
+
 // BuildGraphPath cleans and construct a valid graph path string
 // from the given components. Any parameter may be an empty string
 // to omit that portion of the path, although relative or partial
@@ -198,7 +659,8 @@ func BuildGraphPath(location, component, port string) string {
 
 // SplitGraphPath splits a graph path into its three
 // components given the shape of the path is:
-//  graph/node.port
+//
+//	graph/node.port
 //
 // if no graph is specified, a "." is returned.
 func SplitGraphPath(graphPath string) (graph, node, port string) {
@@ -1,5 +1,7 @@
 package churn
 
+import "time"
+
 // GraphOption is a type that applies one or more initialization
 // options to a new graph instance
 type GraphOption interface {
@@ -19,3 +21,19 @@ func ChannelBufferSize(size int) GraphOption {
 		g.channelBufferSize = size
 	})
 }
+
+// HandlerTimeout sets a deadline on the context given to
+// context-aware in port handlers for each message
+func HandlerTimeout(timeout time.Duration) GraphOption {
+	return OptionFunc(func(g *Graph) {
+		g.handlerTimeout = timeout
+	})
+}
+
+// ErrorHandler sets a function to be called with any error
+// returned by an in port handler in the graph network
+func ErrorHandler(onError func(error)) GraphOption {
+	return OptionFunc(func(g *Graph) {
+		g.onError = onError
+	})
+}
@@ -1,8 +1,10 @@
 package churn
 
 import (
+	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -181,4 +183,439 @@ func TestSplitGraphPath_PartialPaths(t *testing.T) {
 		t.Errorf("expected (., , port), got: (%s, %s, %s)", loc, name, port)
 	}
 
+	loc, name, port = SplitGraphPath("name.port:arg")
+	if loc != "." || name != "name" || port != "port:arg" {
+		t.Errorf("expected (., name, port:arg), got: (%s, %s, %s)", loc, name, port)
+	}
+
+}
+
+type relayNode struct {
+	BaseNode
+	InValue  <-chan string
+	OutValue chan string
+	err      error
+}
+
+func (n *relayNode) Run(ctx context.Context) error {
+
+	for {
+		select {
+		case <-ctx.Done():
+			return n.err
+		case v := <-n.InValue:
+			n.OutValue <- v
+		}
+	}
+
+}
+
+type collectNode struct {
+	BaseNode
+	values chan string
+}
+
+func (n *collectNode) InValue(v string) { n.values <- v }
+
+func TestGraph_Run(t *testing.T) {
+
+	graph := NewGraph()
+	source := new(StringNode)
+	collector := &collectNode{values: make(chan string, 1)}
+	graph.Add("Source", source)
+	graph.Add("Relay", new(relayNode))
+	graph.Add("Collector", collector)
+
+	if err := graph.Connect("Source.Value", "Relay.Value"); err != nil {
+		t.Fatal(err)
+	}
+	if err := graph.Connect("Relay.Value", "Collector.Value"); err != nil {
+		t.Fatal(err)
+	}
+	graph.Start()
+
+	source.OutValue <- "relayed"
+	select {
+	case actual := <-collector.values:
+		if actual != "relayed" {
+			t.Errorf("expected value to pass through running node, got %q", actual)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for value from running node")
+	}
+
+	graph.Close()
+
+}
+
+type greetNode struct {
+	BaseNode
+	OutValue chan string
+}
+
+func (n *greetNode) Run(ctx context.Context) error {
+
+	n.OutValue <- "hello"
+	<-ctx.Done()
+	return nil
+
+}
+
+func TestGraph_Start(t *testing.T) {
+
+	graph := NewGraph()
+	defer graph.Close()
+	sub := NewGraph()
+	collector := &collectNode{values: make(chan string, 1)}
+	graph.Add("Sub", sub)
+	sub.Add("Greet", new(greetNode))
+	graph.Add("Collector", collector)
+
+	// the greeting is only sent once the graph is started,
+	// by which time its out port is connected
+	time.Sleep(10 * time.Millisecond)
+	graph.Connect("Sub/Greet.Value", "Collector.Value")
+	graph.Start()
+
+	select {
+	case actual := <-collector.values:
+		if actual != "hello" {
+			t.Errorf("expected greeting from started node, got %q", actual)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for value from started node")
+	}
+
+}
+
+func TestGraph_Stop(t *testing.T) {
+
+	graph := NewGraph()
+	expected := errors.New("run failed")
+	graph.Add("Relay", &relayNode{err: expected})
+	graph.Start()
+
+	err := graph.Stop()
+	if errors.Cause(err) != expected {
+		t.Errorf("expected Stop to return the error from Run, got %v", err)
+	}
+
+}
+
+type slowEchoNode struct {
+	BaseNode
+	OutNext chan int64
+	started chan struct{}
+	release chan struct{}
+}
+
+func (n *slowEchoNode) InValue(v int64) {
+
+	close(n.started)
+	<-n.release
+	n.OutNext <- v
+
+}
+
+func TestGraph_CloseWaitsForHandlers(t *testing.T) {
+
+	errs := make(chan error, 1)
+	graph := NewGraph(ErrorHandler(func(err error) { errs <- err }))
+	source := new(IntNode)
+	echo := &slowEchoNode{started: make(chan struct{}), release: make(chan struct{})}
+	graph.Add("Source", source)
+	graph.Add("Echo", echo)
+	graph.Connect("Source.Value", "Echo.Value")
+
+	source.OutValue <- 1
+	<-echo.started
+	closed := make(chan struct{})
+	go func() {
+		graph.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+		t.Fatal("expected Close to wait for the running handler")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(echo.release)
+	<-closed
+	select {
+	case err := <-errs:
+		t.Errorf("expected handler to send before its out port was closed, got %v", err)
+	default:
+	}
+
+}
+
+func TestGraph_RemoveWaitsForHandlers(t *testing.T) {
+
+	errs := make(chan error, 1)
+	graph := NewGraph(ErrorHandler(func(err error) { errs <- err }))
+	source := new(IntNode)
+	echo := &slowEchoNode{started: make(chan struct{}), release: make(chan struct{})}
+	graph.Add("Source", source)
+	graph.Add("Echo", echo)
+	defer graph.Close()
+	graph.Connect("Source.Value", "Echo.Value")
+
+	source.OutValue <- 1
+	<-echo.started
+	removed := make(chan error, 1)
+	go func() { removed <- graph.Remove("Echo") }()
+
+	select {
+	case <-removed:
+		t.Fatal("expected Remove to wait for the running handler")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(echo.release)
+	if err := <-removed; err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		t.Errorf("expected handler to send before its out port was closed, got %v", err)
+	default:
+	}
+
+}
+
+func TestGraph_RemoveConcurrent(t *testing.T) {
+
+	graph := NewGraph()
+	defer graph.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			graph.Add("Source", new(IntNode))
+			graph.Remove("Source")
+		}
+	}()
+
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+			graph.GetNode("Source")
+		}
+	}
+
+}
+
+type contextNode struct {
+	BaseNode
+	paths chan string
+}
+
+func (n *contextNode) InValue(ctx context.Context, v string) error {
+
+	n.paths <- NodePath(ctx)
+	if CorrelationID(ctx) == "" {
+		return errors.New("missing correlation id")
+	}
+	<-ctx.Done()
+	return ctx.Err()
+
+}
+
+func TestGraph_ContextHandler(t *testing.T) {
+
+	errs := make(chan error, 1)
+	graph := NewGraph(ErrorHandler(func(err error) { errs <- err }))
+	subGraph := NewGraph(HandlerTimeout(time.Millisecond))
+	source := new(StringNode)
+	handler := &contextNode{paths: make(chan string, 1)}
+	subGraph.Add("Handler", handler)
+	graph.Add("Sub", subGraph)
+	graph.Add("Source", source)
+	defer graph.Close()
+
+	if err := graph.Connect("Source.Value", "Sub/Handler.Value"); err != nil {
+		t.Fatal(err)
+	}
+
+	source.OutValue <- "message"
+	if actual := <-handler.paths; actual != "Sub/Handler" {
+		t.Errorf("expected node path in handler context, got %q", actual)
+	}
+
+	select {
+	case err := <-errs:
+		if errors.Cause(err) != context.DeadlineExceeded {
+			t.Errorf("expected handler deadline to be exceeded, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for handler error")
+	}
+
+}
+
+type doubleNode struct {
+	BaseNode
+	OutDoubled chan int64 `result:"Tagged"`
+}
+
+func (*doubleNode) InValue(v int64) (int64, error)  { return v * 2, nil }
+func (*doubleNode) InTagged(v int64) (int64, error) { return v * 2, nil }
+
+type collectIntNode struct {
+	BaseNode
+	values chan int64
+}
+
+func (n *collectIntNode) InValue(v int64) { n.values <- v }
+
+func TestGraph_FunctionalPorts(t *testing.T) {
+
+	graph := NewGraph()
+	source := new(IntNode)
+	collector := &collectIntNode{values: make(chan int64, 2)}
+	graph.Add("Source", source)
+	graph.Add("Double", new(doubleNode))
+	graph.Add("Collector", collector)
+	defer graph.Close()
+
+	connections := [][2]string{
+		{"Source.Value", "Double.Value"},
+		{"Source.Value", "Double.Tagged"},
+		{"Double.ValueResult", "Collector.Value"},
+		{"Double.Doubled", "Collector.Value"},
+	}
+	for _, c := range connections {
+		if err := graph.Connect(c[0], c[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	source.OutValue <- 21
+	for i := 0; i < 2; i++ {
+		if actual := <-collector.values; actual != 42 {
+			t.Errorf("expected doubled result, got %d", actual)
+		}
+	}
+
+}
+
+type headerNode struct{ BaseNode }
+
+func (*headerNode) InValue(ctx context.Context, v string) (string, error) {
+
+	SetHeader(ctx, "seen-by", NodePath(ctx))
+	return v, nil
+
+}
+
+type envelopeNode struct {
+	BaseNode
+	envs chan *Envelope
+}
+
+func (n *envelopeNode) InValue(ctx context.Context, v string) { n.envs <- MessageEnvelope(ctx) }
+
+func TestGraph_Envelope(t *testing.T) {
+
+	graph := NewGraph()
+	source := new(StringNode)
+	collector := &envelopeNode{envs: make(chan *Envelope, 1)}
+	graph.Add("Source", source)
+	graph.Add("Header", new(headerNode))
+	graph.Add("Collector", collector)
+	defer graph.Close()
+
+	graph.Connect("Source.Value", "Header.Value")
+	graph.Connect("Header.ValueResult", "Collector.Value")
+
+	source.OutValue <- "message"
+	env := <-collector.envs
+
+	if env.Source != "Header.ValueResult" || env.Parent == nil || env.Parent.Source != "Source.Value" {
+		t.Errorf("expected envelope to record its provenance, got %+v", env)
+	}
+	if env.CorrelationID == "" || env.CorrelationID != env.Parent.CorrelationID {
+		t.Errorf("expected correlation id to be preserved, got %q", env.CorrelationID)
+	}
+	if env.Header("seen-by") != "Header" {
+		t.Errorf("expected header set by handler, got %v", env.Headers)
+	}
+
+}
+
+type chanRelayNode struct {
+	BaseNode
+	OutValue chan int64
+	ids      map[int64]string
+}
+
+func (n *chanRelayNode) InValue(ctx context.Context, v int64) {
+
+	n.ids[v] = CorrelationID(ctx)
+	n.OutValue <- v
+
+}
+
+type correlationNode struct {
+	BaseNode
+	ids  map[int64]string
+	done chan struct{}
+	last int64
+}
+
+func (n *correlationNode) InValue(ctx context.Context, v int64) {
+
+	n.ids[v] = CorrelationID(ctx)
+	if v == n.last {
+		close(n.done)
+	}
+
+}
+
+func TestGraph_Envelope_ChannelPort(t *testing.T) {
+
+	for _, size := range []int{0, 16} {
+		t.Run(fmt.Sprintf("buffer %d", size), func(t *testing.T) {
+			testEnvelopeChannelPort(t, NewGraph(ChannelBufferSize(size)))
+		})
+	}
+
+}
+
+func testEnvelopeChannelPort(t *testing.T, graph *Graph) {
+
+	const count = 1000
+	source := new(IntNode)
+	relay := &chanRelayNode{ids: make(map[int64]string)}
+	sink := &correlationNode{ids: make(map[int64]string), done: make(chan struct{}), last: count - 1}
+	graph.Add("Source", source)
+	graph.Add("Relay", relay)
+	graph.Add("Sink", sink)
+	defer graph.Close()
+	graph.Connect("Source.Value", "Relay.Value")
+	graph.Connect("Relay.Value", "Sink.Value")
+
+	// values written to a channel are related to the message being
+	// handled when they were written, not to whichever came after
+	for i := int64(0); i < count; i++ {
+		source.OutValue <- i
+	}
+	select {
+	case <-sink.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for values")
+	}
+	mismatched := 0
+	for v, id := range sink.ids {
+		if id != relay.ids[v] {
+			mismatched++
+		}
+	}
+	if mismatched > 0 {
+		t.Errorf("expected every value to keep its correlation id, %d of %d did not", mismatched, count)
+	}
+
 }
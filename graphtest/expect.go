@@ -0,0 +1,31 @@
+package graphtest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// ExpectValues fails the test unless the recorder holds exactly
+// the expected values, in order, within DefaultTimeout
+func ExpectValues(t testing.TB, r *Recorder, expected ...interface{}) {
+
+	t.Helper()
+	ExpectWithin(t, r, DefaultTimeout, expected...)
+
+}
+
+// ExpectWithin fails the test unless the recorder holds exactly
+// the expected values, in order, within 'timeout'
+func ExpectWithin(t testing.TB, r *Recorder, timeout time.Duration, expected ...interface{}) {
+
+	t.Helper()
+	actual := r.Wait(len(expected), timeout)
+	if len(actual) == 0 && len(expected) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected values %v, got %v", expected, actual)
+	}
+
+}
@@ -0,0 +1,90 @@
+package graphtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rydrman/churn"
+)
+
+type doubleNode struct {
+	churn.BaseNode
+}
+
+func (*doubleNode) InValue(v int64) (int64, error) { return v * 2, nil }
+
+func TestProbe(t *testing.T) {
+
+	graph := churn.NewGraph()
+	source := new(churn.IntNode)
+	graph.Add("Source", source)
+	graph.Add("Double", new(doubleNode))
+	defer graph.Close()
+	graph.Connect("Source.Value", "Double.Value")
+
+	probe, err := Probe(graph, "Double.ValueResult")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer probe.Close()
+
+	source.OutValue <- 1
+	source.OutValue <- 2
+	ExpectValues(t, probe, int64(2), int64(4))
+
+	if _, err := Probe(graph, "Double.Missing"); !churn.IsPortNotExist(err) {
+		t.Errorf("expected missing port error, got %v", err)
+	}
+
+}
+
+func TestInject(t *testing.T) {
+
+	graph := churn.NewGraph(churn.Deterministic())
+	graph.Add("Double", new(doubleNode))
+	defer graph.Close()
+
+	probe, err := Probe(graph, "Double.ValueResult")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// int32 widens to the int64 port
+	if err := Inject(graph, "Double.Value", int32(3)); err != nil {
+		t.Fatal(err)
+	}
+	ExpectWithin(t, probe, 100*time.Millisecond, int64(6))
+
+	err = Inject(graph, "Double.Value", "three")
+	if !churn.IsTypeMismatch(err) {
+		t.Errorf("expected type mismatch error, got %v", err)
+	}
+
+}
+
+func TestHarness(t *testing.T) {
+
+	h, err := NewHarness(new(doubleNode), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	// int32 widens to the int64 port
+	if err := h.Send("Value", int32(5)); err != nil {
+		t.Fatal(err)
+	}
+	// without a graph, results are recorded before Send returns
+	if values := h.Output("ValueResult").Values(); len(values) != 1 || values[0] != int64(10) {
+		t.Errorf("expected [10] to be recorded, got %v", values)
+	}
+
+	if err := h.Send("Missing", 1); !churn.IsPortNotExist(err) {
+		t.Errorf("expected missing port error, got %v", err)
+	}
+
+	if h.Output("Missing") != nil {
+		t.Error("expected no recorder for a missing port")
+	}
+
+}
@@ -0,0 +1,59 @@
+package graphtest
+
+import "github.com/rydrman/churn"
+
+// Harness runs a single node in isolation, without a graph,
+// recording everything that it sends from its out ports
+type Harness struct {
+	isolated *churn.Isolated
+	outputs  map[string]*Recorder
+}
+
+// NewHarness sets up 'node' for testing. The node is initialized,
+// and run if it is a runner, as it would be when added to a graph.
+// Errors from the node are passed to 'onError', or logged if it is nil
+func NewHarness(node churn.Node, onError func(error)) (*Harness, error) {
+
+	isolated, err := churn.Isolate(node, onError)
+	if err != nil {
+		return nil, err
+	}
+	h := &Harness{
+		isolated: isolated,
+		outputs:  make(map[string]*Recorder),
+	}
+
+	for _, port := range isolated.Ports().Outs {
+		r := newRecorder(nil)
+		r.cancel, err = isolated.Observe(port.Name, r.record)
+		if err != nil {
+			h.Close()
+			return nil, err
+		}
+		h.outputs[port.Name] = r
+	}
+	return h, nil
+
+}
+
+// Send delivers a value to the named in port of
+// the node, returning once it has been handled
+func (h *Harness) Send(portName string, value interface{}) error {
+	return h.isolated.Deliver(portName, value)
+}
+
+// Output returns the recorder for the named out port of
+// the node, or nil if the node has no such port
+func (h *Harness) Output(portName string) *Recorder {
+	return h.outputs[portName]
+}
+
+// Close stops and closes the node
+func (h *Harness) Close() {
+
+	for _, r := range h.outputs {
+		r.Close()
+	}
+	h.isolated.Close()
+
+}
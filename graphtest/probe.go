@@ -0,0 +1,120 @@
+// Package graphtest provides utilities for testing churn nodes and graphs
+package graphtest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rydrman/churn"
+)
+
+// DefaultTimeout is how long ExpectValues waits for values to arrive
+var DefaultTimeout = time.Second
+
+// Recorder holds every value sent from a probed out port
+type Recorder struct {
+	// settle steps the graph of the port, if any
+	settle func()
+	cancel func()
+
+	mutex  sync.Mutex
+	values []interface{}
+	notify chan struct{}
+}
+
+// Probe starts recording every value sent from the given out port
+// of 'graph', until the returned recorder is closed
+func Probe(graph *churn.Graph, portPath string) (*Recorder, error) {
+
+	r := newRecorder(func() { graph.Settle() })
+	cancel, err := graph.Observe(portPath, r.record)
+	if err != nil {
+		return nil, err
+	}
+	r.cancel = cancel
+	return r, nil
+
+}
+
+func newRecorder(settle func()) *Recorder {
+
+	return &Recorder{
+		settle: settle,
+		notify: make(chan struct{}, 1),
+	}
+
+}
+
+// Inject delivers a single value directly to the given in port of
+// 'graph', returning once it has been handled. Deterministic graphs
+// are settled so that the value is handled before returning
+func Inject(graph *churn.Graph, portPath string, value interface{}) error {
+
+	if err := graph.Deliver(portPath, value); err != nil {
+		return err
+	}
+	graph.Settle()
+	return nil
+
+}
+
+// Values returns a copy of the values recorded so far
+func (r *Recorder) Values() []interface{} {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]interface{}(nil), r.values...)
+
+}
+
+// Wait waits until at least 'n' values have been recorded, or for
+// 'timeout', returning the values recorded so far. Deterministic
+// graphs are settled while waiting
+func (r *Recorder) Wait(n int, timeout time.Duration) []interface{} {
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		if r.settle != nil {
+			r.settle()
+		}
+		values := r.Values()
+		if len(values) >= n {
+			return values
+		}
+		select {
+		case <-r.notify:
+		case <-deadline.C:
+			return r.Values()
+		case <-time.After(10 * time.Millisecond):
+			// poll in case the graph needs to be stepped
+		}
+	}
+
+}
+
+// Reset discards all values recorded so far
+func (r *Recorder) Reset() {
+
+	r.mutex.Lock()
+	r.values = nil
+	r.mutex.Unlock()
+
+}
+
+// Close stops recording values
+func (r *Recorder) Close() {
+	r.cancel()
+}
+
+func (r *Recorder) record(value interface{}) {
+
+	r.mutex.Lock()
+	r.values = append(r.values, value)
+	r.mutex.Unlock()
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+
+}
@@ -0,0 +1,135 @@
+package churn
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/rydrman/churn/churncore"
+
+	"github.com/pkg/errors"
+)
+
+// inlet feeds values from outside the graph into an in port
+type inlet struct {
+	sender *churncore.Sender
+	subs   *churncore.Subscription
+}
+
+// Send passes a single value from outside the graph to the given in
+// port. Values sent to the same port are queued and handled in order,
+// just as if they came from a connection, and Send blocks while the
+// queue is full until 'ctx' is done. The value must be assignable or
+// convertible to the type of the port. If 'ctx' is the context of
+// an in port handler, the message is related to the one being handled.
+// Sending to a graph that has been closed returns ErrClosed
+func (g *Graph) Send(ctx context.Context, portPath string, value interface{}) error {
+
+	port := g.GetInPort(portPath)
+	if port == nil {
+		return errors.Wrap(ErrPortNotExist, portPath)
+	}
+	owner := g.owner(portPath)
+
+	val, transforms, err := owner.prepare(port.DataType(), value)
+	if err != nil {
+		return errors.Wrap(err, portPath)
+	}
+	// values are converted before they are queued so that
+	// a single inlet keeps all values sent to the port in order
+	for _, t := range transforms {
+		if val, _, err = t.Apply(val); err != nil {
+			return errors.Wrap(err, portPath)
+		}
+	}
+
+	sender, err := owner.inlet(port, fullPortPath(g.Path(), portPath))
+	if err != nil {
+		return errors.Wrap(err, portPath)
+	}
+	parent := churncore.EnvelopeFromContext(ctx)
+	return errors.Wrap(sender.SendContext(ctx, parent, val), portPath)
+
+}
+
+// Inlet returns a function that sends values of type 'T' to the given
+// in port, as with Graph.Send. Values of type 'T' must be assignable
+// or convertible to the type of the port
+func Inlet[T any](g *Graph, portPath string) (func(ctx context.Context, value T) error, error) {
+
+	port := g.GetInPort(portPath)
+	if port == nil {
+		return nil, errors.Wrap(ErrPortNotExist, portPath)
+	}
+
+	from := reflect.TypeOf((*T)(nil)).Elem()
+	to := port.DataType()
+	if !from.AssignableTo(to) && g.owner(portPath).conversion(from, to) == nil {
+		return nil, errors.Wrapf(
+			ErrTypeMismatch, "%s: cannot send [%s] to [%s]", portPath, from, to,
+		)
+	}
+
+	return func(ctx context.Context, value T) error {
+		return g.Send(ctx, portPath, value)
+	}, nil
+
+}
+
+// inlet returns the sender that feeds values into an
+// in port of this graph, creating it if needed
+func (g *Graph) inlet(port *Port, fullPath string) (*churncore.Sender, error) {
+
+	g.inletLock.Lock()
+	defer g.inletLock.Unlock()
+
+	if g.inletsClosed {
+		return nil, ErrClosed
+	}
+
+	if in, ok := g.inlets[port]; ok {
+		return in.sender, nil
+	}
+
+	sender := churncore.NewDirectSender(port.DataType())
+	sender.SetScheduler(g.scheduler)
+	sender.SetSource(func() string { return fullPath })
+	subs, err := sender.SubscribeWith(
+		port.core.(*churncore.Receiver),
+		churncore.SubscribeOptions{QueueSize: g.queueSize()},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if g.inlets == nil {
+		g.inlets = make(map[*Port]*inlet)
+	}
+	g.inlets[port] = &inlet{sender: sender, subs: subs}
+	return sender, nil
+
+}
+
+// closeInlets ends the delivery of all values sent
+// from outside the graph, and stops any more from being sent
+func (g *Graph) closeInlets() {
+
+	g.inletLock.Lock()
+	defer g.inletLock.Unlock()
+	g.inletsClosed = true
+	for port, in := range g.inlets {
+		in.subs.Close()
+		delete(g.inlets, port)
+	}
+
+}
+
+// queueSize returns the size of the queues that this graph
+// creates for its own subscriptions, which is never zero
+func (g *Graph) queueSize() int {
+
+	if g.channelBufferSize > 1 {
+		return g.channelBufferSize
+	}
+	return 1
+
+}
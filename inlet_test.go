@@ -0,0 +1,105 @@
+package churn
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestGraph_Send(t *testing.T) {
+
+	graph := NewGraph()
+	collector := &collectIntNode{values: make(chan int64, 2)}
+	graph.Add("Collector", collector)
+	defer graph.Close()
+
+	ctx := context.Background()
+	if err := graph.Send(ctx, "Collector.Value", int64(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := graph.Send(ctx, "Collector.Value", int32(2)); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []int64{1, 2} {
+		if actual := <-collector.values; actual != expected {
+			t.Errorf("expected %d, got %d", expected, actual)
+		}
+	}
+
+	if err := graph.Send(ctx, "Collector.Value", "3"); !IsTypeMismatch(err) {
+		t.Errorf("expected type mismatch error, got %v", err)
+	}
+	if err := graph.Send(ctx, "Collector.Missing", 3); !IsPortNotExist(err) {
+		t.Errorf("expected missing port error, got %v", err)
+	}
+
+}
+
+func TestGraph_Send_Closed(t *testing.T) {
+
+	graph := NewGraph()
+	collector := &collectIntNode{values: make(chan int64, 1)}
+	graph.Add("Collector", collector)
+	graph.Close()
+
+	err := graph.Send(context.Background(), "Collector.Value", int64(1))
+	if !IsClosed(err) {
+		t.Errorf("expected closed error, got %v", err)
+	}
+	graph.inletLock.Lock()
+	defer graph.inletLock.Unlock()
+	if len(graph.inlets) != 0 {
+		t.Error("expected no inlet to be created after close")
+	}
+
+}
+
+func TestGraph_Send_BackPressure(t *testing.T) {
+
+	graph := NewGraph()
+	block := &blockNode{stop: make(chan struct{})}
+	graph.Add("Block", block)
+	defer graph.Close()
+	defer close(block.stop)
+
+	// one value is being handled and another is queued
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		if err := graph.Send(ctx, "Block.Value", int64(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := graph.Send(ctx, "Block.Value", int64(2))
+	if errors.Cause(err) != context.DeadlineExceeded {
+		t.Errorf("expected send to wait until the deadline, got %v", err)
+	}
+
+}
+
+func TestInlet(t *testing.T) {
+
+	graph := NewGraph()
+	collector := &collectIntNode{values: make(chan int64, 1)}
+	graph.Add("Collector", collector)
+	defer graph.Close()
+
+	send, err := Inlet[int64](graph, "Collector.Value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := send(context.Background(), 5); err != nil {
+		t.Fatal(err)
+	}
+	if actual := <-collector.values; actual != 5 {
+		t.Errorf("expected 5, got %d", actual)
+	}
+
+	if _, err := Inlet[string](graph, "Collector.Value"); !IsTypeMismatch(err) {
+		t.Errorf("expected type mismatch error, got %v", err)
+	}
+
+}
@@ -0,0 +1,171 @@
+package churn
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"sync"
+
+	"github.com/rydrman/churn/churncore"
+
+	"github.com/pkg/errors"
+)
+
+// Isolated is a node that has been set up outside of any
+// graph, so that it can be driven directly through its ports
+type Isolated struct {
+	node    Node
+	onError func(error)
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+}
+
+// Isolate sets up and initializes a node that belongs to no graph,
+// and runs it if it is a Runner. Errors from its handlers or from Run
+// are passed to 'onError', or logged if it is nil. Values given to
+// its ports are only converted by the builtin conversions
+func Isolate(node Node, onError func(error)) (*Isolated, error) {
+
+	if err := node.setupBaseNode(node, nil, ""); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	i := &Isolated{
+		node:    node,
+		onError: onError,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	lineage := new(churncore.Lineage)
+	for _, port := range node.catalog().Ins {
+		if receiver, ok := port.core.(*churncore.Receiver); ok {
+			portName := port.Name
+			receiver.SetContext(ctx)
+			receiver.SetLineage(lineage)
+			receiver.SetErrorHandler(func(err error) {
+				i.reportError(errors.Wrap(err, portName))
+			})
+		}
+	}
+	for _, port := range node.catalog().Outs {
+		if sender, ok := port.core.(*churncore.Sender); ok {
+			sender.SetLineage(lineage)
+		}
+	}
+	node.Init()
+
+	runner, isRunner := node.(Runner)
+	if !isRunner {
+		close(i.done)
+		return i, nil
+	}
+	go func() {
+		defer close(i.done)
+		err := runner.Run(ctx)
+		if err != nil && errors.Cause(err) != context.Canceled {
+			i.reportError(err)
+		}
+	}()
+	return i, nil
+
+}
+
+// Node returns the node that was isolated
+func (i *Isolated) Node() Node {
+	return i.node
+}
+
+// Ports returns the ports of the isolated node
+func (i *Isolated) Ports() *PortCatalog {
+	return i.node.catalog()
+}
+
+// Deliver hands a single message directly to the named in port, and
+// returns once it has been handled. The value must be assignable
+// or convertible to the type of the port
+func (i *Isolated) Deliver(portName string, value interface{}) error {
+
+	port := i.node.In(portName)
+	if port == nil {
+		return errors.Wrap(ErrPortNotExist, portName)
+	}
+	receiver := port.core.(*churncore.Receiver)
+
+	val, transforms, err := prepareValue(receiver.DataType(), value, isolatedConversion)
+	if err != nil {
+		return errors.Wrap(err, portName)
+	}
+
+	sender := churncore.NewDirectSender(val.Type())
+	subs, err := sender.Subscribe(receiver, transforms...)
+	if err != nil {
+		return errors.Wrap(err, portName)
+	}
+	defer subs.Close()
+	sender.Send(nil, val)
+	return nil
+
+}
+
+// Observe calls 'observe' with every message sent from the named out
+// port, until the returned cancel function is called. The function
+// is called from the goroutine of the sender, and should return quickly
+func (i *Isolated) Observe(portName string, observe func(value interface{})) (cancel func(), err error) {
+
+	port := i.node.Out(portName)
+	if port == nil {
+		return nil, errors.Wrap(ErrPortNotExist, portName)
+	}
+	sender := port.core.(*churncore.Sender)
+	subs, err := sender.Subscribe(churncore.NewRelayReceiver(
+		sender.DataType(),
+		func(_ *Envelope, val reflect.Value) { observe(val.Interface()) },
+	))
+	if err != nil {
+		return nil, errors.Wrap(err, portName)
+	}
+	return subs.Close, nil
+
+}
+
+// Close stops the node, waiting for it to stop running,
+// and closes its ports. It is safe to call more than once
+func (i *Isolated) Close() {
+
+	i.once.Do(func() {
+		i.cancel()
+		<-i.done
+		i.node.close()
+	})
+
+}
+
+func (i *Isolated) reportError(err error) {
+
+	if i.onError != nil {
+		i.onError(err)
+		return
+	}
+	// errors are otherwise lost, so they are always logged
+	slog.Default().Error("unhandled error", "error", err)
+
+}
+
+// isolatedConversion converts values for isolated
+// nodes, which only have the builtin conversions
+func isolatedConversion(from, to reflect.Type) *churncore.Transform {
+
+	if from.AssignableTo(to) {
+		return nil
+	}
+	convert := builtinConversion(from, to)
+	if convert == nil {
+		return nil
+	}
+	return churncore.NewConversion(from, to, convert)
+
+}
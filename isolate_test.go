@@ -0,0 +1,92 @@
+package churn
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsolate(t *testing.T) {
+
+	node := new(doubleNode)
+	isolated, err := Isolate(node, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer isolated.Close()
+
+	var results []interface{}
+	cancel, err := isolated.Observe("ValueResult", func(v interface{}) { results = append(results, v) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+	doubled := make(chan interface{}, 1)
+	cancel, err = isolated.Observe("Doubled", func(v interface{}) { doubled <- v })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+
+	// int32 widens to the int64 port, and the result is
+	// sent before Deliver returns since there is no channel
+	if err := isolated.Deliver("Value", int32(3)); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0] != int64(6) {
+		t.Errorf("expected [6], got %v", results)
+	}
+
+	if err := isolated.Deliver("Tagged", int64(4)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case v := <-doubled:
+		if v != int64(8) {
+			t.Errorf("expected 8, got %v", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected result on the tagged out port")
+	}
+
+	if err := isolated.Deliver("Value", "three"); !IsTypeMismatch(err) {
+		t.Errorf("expected type mismatch error, got %v", err)
+	}
+	if err := isolated.Deliver("Missing", 1); !IsPortNotExist(err) {
+		t.Errorf("expected missing port error, got %v", err)
+	}
+	if _, err := isolated.Observe("Missing", func(interface{}) {}); !IsPortNotExist(err) {
+		t.Errorf("expected missing port error, got %v", err)
+	}
+
+}
+
+func TestIsolate_Errors(t *testing.T) {
+
+	errs := make(chan error, 10)
+	isolated, err := Isolate(new(failNode), func(err error) { errs <- err })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := isolated.Deliver("Value", "failed"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		if err.Error() != "Value: failed" {
+			t.Errorf("expected handler error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected handler error to be reported")
+	}
+
+	// the runner is stopped, and being cancelled is not an error
+	isolated.Close()
+	isolated.Close()
+	select {
+	case err := <-errs:
+		t.Errorf("expected no error from stopping, got %v", err)
+	default:
+	}
+
+}
@@ -0,0 +1,88 @@
+package churn
+
+import (
+	"strconv"
+
+	"github.com/rydrman/churn/churncore"
+
+	"github.com/pkg/errors"
+)
+
+// JoinPolicy decides when a multi-parameter in port
+// is called, and which argument values are consumed
+type JoinPolicy = churncore.JoinPolicy
+
+// The available join policies
+const (
+	// JoinZip waits for a value on every argument, using
+	// each value exactly once
+	JoinZip = churncore.JoinZip
+	// JoinLatest fires with the latest value of each argument
+	// whenever any argument receives a new value
+	JoinLatest = churncore.JoinLatest
+	// JoinCorrelated is like JoinZip, but only combines values
+	// with the same correlation id, as needed by concurrent calls
+	JoinCorrelated = churncore.JoinCorrelated
+)
+
+// Join describes how the arguments of a multi-parameter
+// in port are named and combined
+type Join struct {
+	// Args names each argument of the in port method in order, and
+	// defaults to the argument's index. Each argument is exposed as
+	// its own port named "<Port>:<Arg>"
+	Args   []string
+	Policy JoinPolicy
+	// Sticky names arguments that keep their latest value
+	// rather than being consumed under JoinZip
+	Sticky []string
+}
+
+// Joiner can be implemented by nodes with multi-parameter in ports
+// to name their arguments and select a join policy. The returned
+// map is keyed by in port name
+type Joiner interface {
+	Joins() map[string]Join
+}
+
+// joinArgSeparator separates the name of a join from the name of
+// its argument in their port names. It cannot appear in a go method
+// name, or be confused with the separators of graph paths
+const joinArgSeparator = ":"
+
+// joinPorts creates one in port for each argument of a join
+func joinPorts(name string, core *churncore.Join, spec Join) (PortSlice, error) {
+
+	receivers := core.Receivers()
+	args := spec.Args
+	if args == nil {
+		for i := range receivers {
+			args = append(args, strconv.Itoa(i))
+		}
+	}
+	if len(args) != len(receivers) {
+		return nil, errors.Wrapf(
+			ErrInvalidJoin, "%s: expected %d argument names, got %d",
+			name, len(receivers), len(args),
+		)
+	}
+
+	var ports PortSlice
+	for i, receiver := range receivers {
+		ports = append(ports, &Port{
+			Name: name + joinArgSeparator + args[i],
+			core: receiver,
+		})
+	}
+
+	for _, sticky := range spec.Sticky {
+		port := ports.FindByName(name + joinArgSeparator + sticky)
+		if port == nil {
+			return nil, errors.Wrapf(ErrInvalidJoin, "%s: unknown sticky argument %q", name, sticky)
+		}
+		core.SetSticky(port.core.(*churncore.Receiver).Index(), true)
+	}
+
+	return ports, nil
+
+}
@@ -0,0 +1,81 @@
+package churn
+
+import (
+	"reflect"
+	"testing"
+)
+
+type addNode struct{ BaseNode }
+
+func (*addNode) InSum(a, b float64) (float64, error) { return a + b, nil }
+
+func (*addNode) Joins() map[string]Join {
+	return map[string]Join{
+		"Sum": {Args: []string{"a", "b"}, Policy: JoinLatest},
+	}
+}
+
+type badJoinNode struct{ addNode }
+
+func (*badJoinNode) Joins() map[string]Join {
+	return map[string]Join{
+		"Sum": {Args: []string{"a", "b"}, Sticky: []string{"c"}},
+	}
+}
+
+func TestPortCatalog_catalogInPorts_Join(t *testing.T) {
+
+	n := new(addNode)
+	err := n.catalogInPorts(reflect.ValueOf(n), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n.In("Sum:a") == nil || n.In("Sum:b") == nil {
+		t.Errorf("expected a port for each join argument, got %v", n.Ins)
+	}
+
+	bad := new(badJoinNode)
+	err = bad.catalogInPorts(reflect.ValueOf(bad), 0, nil)
+	if !IsInvalidJoin(err) {
+		t.Errorf("expected ErrInvalidJoin for unknown sticky argument, got %v", err)
+	}
+
+}
+
+func TestGraph_Join(t *testing.T) {
+
+	graph := NewGraph()
+	a, b := new(FloatNode), new(FloatNode)
+	sums := make(chan float64, 1)
+	graph.Add("A", a)
+	graph.Add("B", b)
+	graph.Add("Add", new(addNode))
+	defer graph.Close()
+
+	graph.Connect("A.Value", "Add.Sum:a")
+	graph.Connect("B.Value", "Add.Sum:b")
+	sender := graph.GetOutPort("Add.SumResult")
+	if sender == nil {
+		t.Fatal("expected join result port to be cataloged")
+	}
+
+	graph.Add("Collector", &collectFloatNode{values: sums})
+	if err := graph.Connect("Add.SumResult", "Collector.Value"); err != nil {
+		t.Fatal(err)
+	}
+
+	a.OutValue <- 1
+	b.OutValue <- 2
+	if actual := <-sums; actual != 3 {
+		t.Errorf("expected sum of both arguments, got %v", actual)
+	}
+
+}
+
+type collectFloatNode struct {
+	BaseNode
+	values chan float64
+}
+
+func (n *collectFloatNode) InValue(v float64) { n.values <- v }
@@ -0,0 +1,146 @@
+package churn
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+)
+
+// Logger sets the handler of all log records written by the nodes
+// in the graph network and by the graph itself. Sub-graphs use the
+// handler of the closest graph that has one, or else the handler
+// of slog.Default
+func Logger(handler slog.Handler) GraphOption {
+	return OptionFunc(func(g *Graph) {
+		g.logHandler = handler
+	})
+}
+
+// LogLevel sets the minimum level of the log records written by the
+// nodes in the graph network and by the graph itself, in addition to
+// that of the handler. Sub-graphs use the level of the closest graph
+// that has one
+func LogLevel(level slog.Leveler) GraphOption {
+	return OptionFunc(func(g *Graph) {
+		g.logLevel = level
+	})
+}
+
+// Logger returns the logger of this node, whose records include
+// the graph path and type of the node. Nodes that have not been
+// added to a graph use slog.Default
+func (n *BaseNode) Logger() *slog.Logger {
+
+	if n.logger == nil {
+		return slog.Default()
+	}
+	return n.logger
+
+}
+
+// nodeLogger creates the logger of the node identified by 'ref'
+func (g *Graph) nodeLogger(ref *nodeRef, node Node) *slog.Logger {
+
+	nodeType := reflect.TypeOf(node)
+	if nodeType.Kind() == reflect.Ptr {
+		nodeType = nodeType.Elem()
+	}
+	return slog.New(&logHandler{graph: g}).With(
+		slog.Any("node", ref),
+		slog.String("type", nodeType.String()),
+	)
+
+}
+
+// log returns the logger for records written by this graph
+func (g *Graph) log() *slog.Logger {
+	return slog.New(&logHandler{graph: g}).With(slog.Any("graph", graphRef{g}))
+}
+
+// resolveLogging returns the handler and level of the
+// closest graphs that have them, starting with this one
+func (g *Graph) resolveLogging() (slog.Handler, slog.Leveler) {
+
+	var (
+		handler slog.Handler
+		level   slog.Leveler
+	)
+	for ; g != nil && (handler == nil || level == nil); g = g.parent {
+		if handler == nil {
+			handler = g.logHandler
+		}
+		if level == nil {
+			level = g.logLevel
+		}
+	}
+	if handler == nil {
+		handler = slog.Default().Handler()
+	}
+	return handler, level
+
+}
+
+// LogValue resolves the path of the node when it is logged,
+// since a graph can be nested after its nodes are added
+func (r *nodeRef) LogValue() slog.Value {
+	return slog.StringValue(r.path())
+}
+
+// graphRef resolves the path of a graph when it is logged
+type graphRef struct{ graph *Graph }
+
+func (r graphRef) LogValue() slog.Value {
+	return slog.StringValue(r.graph.Path())
+}
+
+// logHandler writes records to the handler of its graph, which is
+// resolved for each record so that it follows the graph's nesting
+type logHandler struct {
+	graph *Graph
+	// ops are applied in order to the resolved handler
+	ops []func(slog.Handler) slog.Handler
+}
+
+func (h *logHandler) Enabled(ctx context.Context, level slog.Level) bool {
+
+	handler, minLevel := h.graph.resolveLogging()
+	if minLevel != nil && level < minLevel.Level() {
+		return false
+	}
+	return handler.Enabled(ctx, level)
+
+}
+
+func (h *logHandler) Handle(ctx context.Context, record slog.Record) error {
+
+	handler, _ := h.graph.resolveLogging()
+	for _, op := range h.ops {
+		handler = op(handler)
+	}
+	return handler.Handle(ctx, record)
+
+}
+
+func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+
+	return h.with(func(handler slog.Handler) slog.Handler {
+		return handler.WithAttrs(attrs)
+	})
+
+}
+
+func (h *logHandler) WithGroup(name string) slog.Handler {
+
+	return h.with(func(handler slog.Handler) slog.Handler {
+		return handler.WithGroup(name)
+	})
+
+}
+
+func (h *logHandler) with(op func(slog.Handler) slog.Handler) *logHandler {
+
+	ops := make([]func(slog.Handler) slog.Handler, len(h.ops), len(h.ops)+1)
+	copy(ops, h.ops)
+	return &logHandler{graph: h.graph, ops: append(ops, op)}
+
+}
@@ -0,0 +1,61 @@
+package churn
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+type logNode struct{ BaseNode }
+
+func (n *logNode) InValue(s string) {
+	n.Logger().Info("info", "value", s)
+	n.Logger().Warn("warn", "value", s)
+}
+
+func TestGraph_Logger(t *testing.T) {
+
+	buf := new(bytes.Buffer)
+	handler := slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+	graph := NewGraph(Deterministic(), Logger(handler))
+	sub := NewGraph(LogLevel(slog.LevelWarn))
+	source := new(StringNode)
+	sub.Add("Log", new(logNode))
+	graph.Add("Source", source)
+	graph.Add("Sub", sub)
+	defer graph.Close()
+	graph.Connect("Source.Value", "Sub/Log.Value")
+
+	source.OutValue <- "hello"
+	graph.Settle()
+
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+
+	var messages []string
+	for _, record := range records {
+		messages = append(messages, record["msg"].(string))
+		if record["msg"] == "info" {
+			t.Error("expected the sub-graph level to filter info records")
+		}
+		if record["msg"] != "warn" {
+			continue
+		}
+		if record["node"] != "Sub/Log" || record["type"] != "churn.logNode" || record["value"] != "hello" {
+			t.Errorf("expected node path and type attributes, got %v", record)
+		}
+	}
+	expected := []string{"node initialized", "component added", "component added", "ports connected", "warn"}
+	if strings.Join(messages, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected records %v, got %v", expected, messages)
+	}
+
+}
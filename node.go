@@ -1,9 +1,15 @@
 package churn
 
+import (
+	"context"
+	"log/slog"
+)
+
 // Node is a graph component that can participate in the
 // graph execution by exposing any number of input and output
 // ports
 type Node interface {
+	Component
 
 	// In returns the in port on this node with the given name,
 	// or nil if no port exists with that name
@@ -15,7 +21,16 @@ type Node interface {
 	// Init is called when this node is being initialized in a graph
 	Init()
 
-	setupBaseNode(node Node)
+	setupBaseNode(node Node, g *Graph, name string) error
+	catalog() *PortCatalog
+}
+
+// Runner can be implemented by nodes that need a long-running
+// goroutine, such as those that read from channel in ports. Run
+// is called in its own goroutine once the node has been initialized,
+// and 'ctx' is cancelled when the graph is stopped
+type Runner interface {
+	Run(ctx context.Context) error
 }
 
 // BaseNode contains the core node logic that must
@@ -23,13 +38,38 @@ type Node interface {
 type BaseNode struct {
 	BaseComponent
 	PortCatalog
+
+	ref    *nodeRef
+	logger *slog.Logger
 }
 
 // Init can be overridden for custom node initialization
 func (n *BaseNode) Init() {}
 
-func (n *BaseNode) setupBaseNode(node Node) {
+// setupBaseNode catalogs the ports of the node as it is added
+// to 'g', or as it is isolated from any graph if 'g' is nil
+func (n *BaseNode) setupBaseNode(node Node, g *Graph, name string) error {
+
+	catalog, err := catalogPorts(node, g)
+	if err != nil {
+		return err
+	}
+	n.PortCatalog = *catalog
+	if g == nil {
+		// isolated nodes log with slog.Default
+		return nil
+	}
+	n.ref = &nodeRef{graph: g, name: name}
+	n.logger = g.nodeLogger(n.ref, node)
+	return nil
+
+}
+
+func (n *BaseNode) catalog() *PortCatalog { return &n.PortCatalog }
+
+func (n *BaseNode) close() {
 
-	n.PortCatalog = *CatalogPorts(node)
+	n.closeInPorts()
+	n.closeOutPorts()
 
 }
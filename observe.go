@@ -0,0 +1,153 @@
+package churn
+
+import (
+	"reflect"
+
+	"github.com/rydrman/churn/churncore"
+
+	"github.com/pkg/errors"
+)
+
+// GetPorts returns the ports of the node identified in the given
+// graph path, or nil if it does not exist or is not a node
+func (g *Graph) GetPorts(nodePath string) *PortCatalog {
+
+	node := g.GetNode(nodePath)
+	if node == nil {
+		return nil
+	}
+	return node.catalog()
+
+}
+
+// Observe calls 'observe' with every message sent from the given out
+// port, or received by the given in port, alongside any connections
+// that the port has, until the returned cancel function is called. Out
+// ports are preferred when a node has in and out ports of the same
+// name. The function is called from the goroutine of the sender, and
+// should return quickly
+func (g *Graph) Observe(portPath string, observe func(value interface{})) (cancel func(), err error) {
+
+	return g.observe(portPath, func(_ *Envelope, val reflect.Value) {
+		observe(val.Interface())
+	})
+
+}
+
+// ObserveEnvelopes is like Observe, but also passes on
+// the envelope of each message
+func (g *Graph) ObserveEnvelopes(
+	portPath string,
+	observe func(env *Envelope, value interface{}),
+) (cancel func(), err error) {
+
+	return g.observe(portPath, func(env *Envelope, val reflect.Value) {
+		observe(env, val.Interface())
+	})
+
+}
+
+// observe is like Observe, but works with the envelope and
+// reflected value of each message
+func (g *Graph) observe(portPath string, observe func(*Envelope, reflect.Value)) (cancel func(), err error) {
+
+	port := g.GetOutPort(portPath)
+	if port == nil {
+		port = g.GetInPort(portPath)
+		if port == nil {
+			return nil, errors.Wrap(ErrPortNotExist, portPath)
+		}
+		return port.core.(*churncore.Receiver).Observe(observe), nil
+	}
+
+	sender := port.core.(*churncore.Sender)
+	subs, err := sender.Subscribe(churncore.NewRelayReceiver(sender.DataType(), observe))
+	if err != nil {
+		return nil, errors.Wrap(err, portPath)
+	}
+	return subs.Close, nil
+
+}
+
+// Deliver hands a single message directly to the given in port,
+// as if it had been sent through a connection, and returns once it
+// has been handled. The value must be assignable or convertible
+// to the type of the port. In a deterministic graph the message
+// is only handled when the graph is next stepped
+func (g *Graph) Deliver(portPath string, value interface{}) error {
+
+	port := g.GetInPort(portPath)
+	if port == nil {
+		return errors.Wrap(ErrPortNotExist, portPath)
+	}
+	receiver := port.core.(*churncore.Receiver)
+	owner := g.owner(portPath)
+
+	val, transforms, err := owner.prepare(receiver.DataType(), value)
+	if err != nil {
+		return errors.Wrap(err, portPath)
+	}
+
+	sender := churncore.NewDirectSender(val.Type())
+	sender.SetScheduler(owner.scheduler)
+	// the message may be left with the scheduler, so
+	// the subscription closes once it has been handled
+	options := churncore.SubscribeOptions{Once: true}
+	if _, err := sender.SubscribeWith(receiver, options, transforms...); err != nil {
+		return errors.Wrap(err, portPath)
+	}
+	sender.Send(nil, val)
+	return nil
+
+}
+
+// prepare checks that a value can be given to a port of the given
+// type, returning the transforms needed to convert it, if any
+func (g *Graph) prepare(dataType reflect.Type, value interface{}) (reflect.Value, []*churncore.Transform, error) {
+	return prepareValue(dataType, value, g.conversion)
+}
+
+// prepareValue is like Graph.prepare, where 'conversion'
+// provides the transform between two types, if there is one
+func prepareValue(
+	dataType reflect.Type,
+	value interface{},
+	conversion func(from, to reflect.Type) *churncore.Transform,
+) (reflect.Value, []*churncore.Transform, error) {
+
+	val := reflect.ValueOf(value)
+	if !val.IsValid() {
+		switch dataType.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map,
+			reflect.Slice, reflect.Chan, reflect.Func:
+			return reflect.Zero(dataType), nil, nil
+		}
+		return val, nil, errors.Wrapf(ErrTypeMismatch, "cannot use nil as [%s]", dataType)
+	}
+
+	if val.Type().AssignableTo(dataType) {
+		return val, nil, nil
+	}
+	if transform := conversion(val.Type(), dataType); transform != nil {
+		return val, []*churncore.Transform{transform}, nil
+	}
+	return val, nil, errors.Wrapf(
+		ErrTypeMismatch, "cannot use [%s] as [%s]", val.Type(), dataType,
+	)
+
+}
+
+// owner returns the graph that directly contains the node
+// in the given graph path, which may be a sub-graph
+func (g *Graph) owner(nodePath string) *Graph {
+
+	location, _, _ := SplitGraphPath(nodePath)
+	if location == "." {
+		return g
+	}
+	if subGraph := g.GetSubGraph(location); subGraph != nil {
+		return subGraph
+	}
+	return g
+
+}
@@ -0,0 +1,62 @@
+package churn
+
+import (
+	"github.com/rydrman/churn/churncore"
+
+	"github.com/pkg/errors"
+)
+
+// Pause holds all messages given to the in ports of the identified
+// node until it is resumed, which blocks the ports that send to it.
+// Runner nodes keep running, and a deterministic graph should not be
+// stepped while any of its nodes are paused
+func (g *Graph) Pause(nodePath string) error {
+
+	receivers, err := g.receivers(nodePath)
+	for _, receiver := range receivers {
+		receiver.Pause()
+	}
+	return err
+
+}
+
+// Resume releases all messages held since the identified node was paused
+func (g *Graph) Resume(nodePath string) error {
+
+	receivers, err := g.receivers(nodePath)
+	for _, receiver := range receivers {
+		receiver.Resume()
+	}
+	return err
+
+}
+
+// Paused returns true if the identified node is currently paused
+func (g *Graph) Paused(nodePath string) bool {
+
+	receivers, _ := g.receivers(nodePath)
+	for _, receiver := range receivers {
+		if receiver.Paused() {
+			return true
+		}
+	}
+	return false
+
+}
+
+// receivers returns the in port receivers of the identified node
+func (g *Graph) receivers(nodePath string) ([]*churncore.Receiver, error) {
+
+	node := g.GetNode(nodePath)
+	if node == nil {
+		return nil, errors.Wrap(ErrComponentNotExist, nodePath)
+	}
+	var receivers []*churncore.Receiver
+	for _, port := range node.catalog().Ins {
+		if receiver, ok := port.core.(*churncore.Receiver); ok {
+			receivers = append(receivers, receiver)
+		}
+	}
+	return receivers, nil
+
+}
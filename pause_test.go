@@ -0,0 +1,49 @@
+package churn
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGraph_Pause(t *testing.T) {
+
+	graph := NewGraph()
+	source := new(IntNode)
+	graph.Add("Source", source)
+	graph.Add("Double", new(doubleNode))
+	defer graph.Close()
+	graph.Connect("Source.Value", "Double.Value")
+	results, cancel, err := TapTyped[int64](graph, "Double.ValueResult")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+
+	if err := graph.Pause("Missing"); !IsComponentNotExist(err) {
+		t.Errorf("expected ErrComponentNotExist, got %v", err)
+	}
+	if err := graph.Pause("Double"); err != nil {
+		t.Fatal(err)
+	}
+	if !graph.Paused("Double") {
+		t.Error("expected the node to be paused")
+	}
+
+	go func() { source.OutValue <- 1 }()
+	select {
+	case v := <-results:
+		t.Fatalf("expected no results while paused, got %v", v)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	graph.Resume("Double")
+	select {
+	case v := <-results:
+		if v != 2 {
+			t.Errorf("expected 2, got %v", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the held message to be handled once resumed")
+	}
+
+}
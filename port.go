@@ -1,16 +1,44 @@
 package churn
 
+import (
+	"reflect"
+
+	"github.com/rydrman/churn/churncore"
+)
+
+// PortOwner identifies who is responsible for the
+// lifetime of the channel behind a port
+type PortOwner int
+
+// The possible owners of a port channel
+const (
+	// GraphOwned channels are created by the graph and
+	// closed by it when the owning node is closed
+	GraphOwned PortOwner = iota
+	// NodeOwned channels were initialized by the node itself
+	// before it was added, and must be closed by the node
+	NodeOwned
+)
+
 // Port is a one-way communication channel presented by a node
 //
 // Ports come in two basic flavours, in and out:
 // - out ports generate data that can move through the graph
 // - in ports respond to data generated by output ports
 type Port struct {
-	Name string
+	Name  string
+	Owner PortOwner
 
 	// either a *churncore.Sender or *churncore.Receiver
 	// depending on if this is an in or out port
 	core interface{}
+
+	// the go channel backing this port, if any
+	channel reflect.Value
+
+	// the name of the in port whose results are
+	// sent through this out port, if any
+	resultOf string
 }
 
 // PortSlice provides helper methods for working with
@@ -29,3 +57,16 @@ func (p PortSlice) FindByName(name string) *Port {
 	return nil
 
 }
+
+// DataType returns the type of message sent or received by this port
+func (p *Port) DataType() reflect.Type {
+
+	switch core := p.core.(type) {
+	case *churncore.Sender:
+		return core.DataType()
+	case *churncore.Receiver:
+		return core.DataType()
+	}
+	return nil
+
+}
@@ -6,6 +6,8 @@ import (
 	"unicode"
 
 	"github.com/rydrman/churn/churncore"
+
+	"github.com/pkg/errors"
 )
 
 // PortCatalog describes a collection of in and out ports
@@ -14,14 +16,39 @@ type PortCatalog struct {
 	Outs PortSlice
 }
 
-// CatalogPorts builds a record for all ports detected on the given node
-func CatalogPorts(node Node) *PortCatalog {
+// CatalogPorts builds a record for all ports detected on the given node.
+// Any port channels that are created will be unbuffered
+func CatalogPorts(node Node) (*PortCatalog, error) {
+
+	return catalogPorts(node, nil)
+
+}
+
+// catalogPorts builds the ports of a node added to 'g', or of a
+// node that belongs to no graph if 'g' is nil
+func catalogPorts(node Node, g *Graph) (*PortCatalog, error) {
+
+	var (
+		bufferSize int
+		done       <-chan struct{}
+		scheduler  *churncore.Scheduler
+	)
+	if g != nil {
+		bufferSize, done, scheduler = g.channelBufferSize, g.ctx.Done(), g.scheduler
+	}
 
 	catalog := new(PortCatalog)
 	nodeVal := reflect.ValueOf(node)
-	catalog.catalogInPorts(nodeVal)
-	catalog.catalogOutPorts(nodeVal)
-	return catalog
+	err := catalog.catalogInPorts(nodeVal, bufferSize, done)
+	if err != nil {
+		return nil, err
+	}
+	err = catalog.catalogOutPorts(nodeVal, bufferSize, scheduler)
+	if err != nil {
+		return nil, err
+	}
+	err = catalog.catalogResultPorts()
+	return catalog, err
 
 }
 
@@ -37,55 +64,109 @@ func (c PortCatalog) Out(name string) *Port {
 	return c.Outs.FindByName(name)
 }
 
-func (c *PortCatalog) catalogInPorts(node reflect.Value) {
+// catalogInPorts finds both method and channel field in ports. Sends
+// into channel ports are abandoned once 'done' is closed
+func (c *PortCatalog) catalogInPorts(node reflect.Value, bufferSize int, done <-chan struct{}) error {
+
+	var joins map[string]Join
+	if joiner, ok := node.Interface().(Joiner); ok {
+		joins = joiner.Joins()
+	}
 
 	nodeType := node.Type()
 	for i := 0; i < nodeType.NumMethod(); i++ {
 
 		meth := nodeType.Method(i)
-		if !strings.HasPrefix(meth.Name, inPortNamePrefix) {
+		name, ok := trimPortPrefix(meth.Name, inPortNamePrefix)
+		if !ok {
 			continue
 		}
 
-		name := strings.TrimPrefix(meth.Name, inPortNamePrefix)
-
-		// just the prefix alone is not enough of a name
-		if name == "" {
+		handler := node.Method(i).Interface()
+		core, err := churncore.NewReceiver(handler)
+		if err == nil {
+			c.Ins = append(c.Ins, &Port{
+				Name: name,
+				core: core,
+			})
 			continue
 		}
 
-		// prefix must be followed by an uppercase letter
-		// to be properly camel-cased
-		if !unicode.IsUpper(rune(name[0])) {
+		spec := joins[name]
+		join, err := churncore.NewJoin(handler, spec.Policy)
+		if err != nil {
+			// a returned error simply means that the method signature
+			// was not in fact valid as an input port
 			continue
 		}
 
-		core, err := churncore.NewReceiver(node.Method(i).Interface())
+		ports, err := joinPorts(name, join, spec)
 		if err != nil {
-			// a returned error simply means that the method signature
-			// was not in fact valid as an input port
+			return err
+		}
+		c.Ins = append(c.Ins, ports...)
+
+	}
+
+	node, nodeType = derefStruct(node)
+	for i := 0; i < nodeType.NumField(); i++ {
+
+		field := nodeType.Field(i)
+		if field.Anonymous {
+			// TODO: support fields from embedded structs
+			continue
+		}
+		name, ok := trimPortPrefix(field.Name, inPortNamePrefix)
+		if !ok {
 			continue
 		}
 
+		if field.Type.Kind() != reflect.Chan ||
+			field.Type.ChanDir() == reflect.SendDir {
+			continue
+		}
+
+		// as with out ports, an existing channel is adopted
+		// but only if we are able to send into it
+		owner := GraphOwned
+		ch := node.Field(i)
+		if !ch.IsNil() {
+			if field.Type.ChanDir() == reflect.RecvDir {
+				return errors.Wrap(ErrRecvOnlyPort, field.Name)
+			}
+			owner = NodeOwned
+		} else {
+			ch = reflect.MakeChan(
+				reflect.ChanOf(reflect.BothDir, field.Type.Elem()), bufferSize,
+			)
+			node.Field(i).Set(ch)
+		}
+
+		core, err := churncore.NewChanReceiver(ch.Interface(), done)
+		panicIfError(err) // should never happend
+
 		c.Ins = append(c.Ins, &Port{
-			Name: name,
-			core: core,
+			Name:    name,
+			Owner:   owner,
+			core:    core,
+			channel: ch,
 		})
 
 	}
 
-}
+	return nil
 
-func (c *PortCatalog) catalogOutPorts(node reflect.Value) {
+}
 
-	nodeType := node.Type()
-	nodeKind := nodeType.Kind()
-	for nodeKind == reflect.Ptr || nodeKind == reflect.Interface {
-		node = node.Elem()
-		nodeType = node.Type()
-		nodeKind = nodeType.Kind()
-	}
+// catalogOutPorts finds channel field out ports, whose messages
+// are delivered by 'scheduler' if it is not nil
+func (c *PortCatalog) catalogOutPorts(
+	node reflect.Value,
+	bufferSize int,
+	scheduler *churncore.Scheduler,
+) error {
 
+	node, nodeType := derefStruct(node)
 	for i := 0; i < nodeType.NumField(); i++ {
 
 		field := nodeType.Field(i)
@@ -93,45 +174,171 @@ func (c *PortCatalog) catalogOutPorts(node reflect.Value) {
 			// TODO: support fields from embedded structs
 			continue
 		}
-		if !strings.HasPrefix(field.Name, outPortNamePrefix) {
+		name, ok := trimPortPrefix(field.Name, outPortNamePrefix)
+		if !ok {
+			continue
+		}
+
+		if field.Type.Kind() != reflect.Chan ||
+			field.Type.ChanDir() == reflect.RecvDir {
 			continue
 		}
 
-		name := strings.TrimPrefix(field.Name, outPortNamePrefix)
+		// a channel that was already set up by the node is
+		// adopted as-is, but only if we are able to read from it
+		owner := GraphOwned
+		ch := node.Field(i)
+		if !ch.IsNil() {
+			if field.Type.ChanDir() == reflect.SendDir {
+				return errors.Wrap(ErrSendOnlyPort, field.Name)
+			}
+			owner = NodeOwned
+		} else {
+			ch = reflect.MakeChan(
+				reflect.ChanOf(reflect.BothDir, field.Type.Elem()), bufferSize,
+			)
+			node.Field(i).Set(ch)
+		}
+
+		core, err := churncore.NewScheduledSender(ch.Interface(), scheduler)
+		panicIfError(err) // should never happend
+
+		c.Outs = append(c.Outs, &Port{
+			Name:     name,
+			Owner:    owner,
+			core:     core,
+			channel:  ch,
+			resultOf: field.Tag.Get(resultTagName),
+		})
 
-		// just the prefix alone is not enough of a name
-		if name == "" {
+	}
+
+	return nil
+
+}
+
+// catalogResultPorts directs the results of functional in ports to
+// an out port. The port is the out port tagged with the in port's name,
+// an out port named "<Name>Result", or a new port with that name
+func (c *PortCatalog) catalogResultPorts() error {
+
+	linked := make(map[string]bool)
+	for _, in := range c.Ins {
+
+		receiver, ok := in.core.(*churncore.Receiver)
+		if !ok || receiver.ResultType() == nil {
 			continue
 		}
 
-		// prefix must be followed by an uppercase letter
-		// to be properly camel-cased
-		if !unicode.IsUpper(rune(name[0])) {
+		// the arguments of a join all share a single result port
+		name := handlerName(in.Name)
+		if linked[name] {
 			continue
 		}
+		linked[name] = true
 
-		if field.Type.Kind() != reflect.Chan ||
-			field.Type.ChanDir() == reflect.RecvDir {
+		out := c.resultPort(name)
+		if out == nil {
+			out = &Port{
+				Name: name + resultPortNameSuffix,
+				core: churncore.NewDirectSender(receiver.ResultType()),
+			}
+			c.Outs = append(c.Outs, out)
+		}
+
+		err := receiver.SetResultSender(out.core.(*churncore.Sender))
+		if err != nil {
+			return errors.Wrap(err, name)
+		}
+
+	}
+
+	return nil
+
+}
+
+func (c *PortCatalog) resultPort(inName string) *Port {
+
+	for _, out := range c.Outs {
+		if out.resultOf == inName {
+			return out
+		}
+	}
+	return c.Outs.FindByName(inName + resultPortNameSuffix)
+
+}
+
+// closeInPorts stops the handlers of all in ports in this catalog,
+// waiting for the calls in progress to return so that none of them
+// can still be sending when the out ports are closed
+func (c *PortCatalog) closeInPorts() {
+
+	for _, in := range c.Ins {
+		if receiver, ok := in.core.(*churncore.Receiver); ok {
+			receiver.Close()
+		}
+	}
+
+}
+
+// closeOutPorts closes the channels of all graph owned out
+// ports in this catalog. Node owned channels are left untouched.
+// In port channels are never closed since the graph cannot know
+// when their senders have stopped
+func (c *PortCatalog) closeOutPorts() {
+
+	for _, port := range c.Outs {
+		if port.Owner != GraphOwned || !port.channel.IsValid() {
 			continue
 		}
+		port.channel.Close()
+		port.channel = reflect.Value{}
+	}
 
-		// NOTE: any previous channel value is blindly
-		// replaced and not closed
-		ch := reflect.MakeChan(
-			reflect.ChanOf(reflect.BothDir, field.Type.Elem()), 0,
-		)
-		node.Field(i).Set(ch)
+}
 
-		// a returned error signifies that this field did not
-		// meet the final requirements, so we move on
-		core, err := churncore.NewSender(ch.Interface())
-		panicIfError(err) // should never happend
+// handlerName returns the name of the in port method that
+// handles the given port, which differs for join arguments
+func handlerName(portName string) string {
+	return strings.SplitN(portName, joinArgSeparator, 2)[0]
+}
 
-		c.Outs = append(c.Outs, &Port{
-			Name: name,
-			core: core,
-		})
+// trimPortPrefix removes the given prefix from a field or method
+// name, returning false if the name is not a valid port name
+func trimPortPrefix(name, prefix string) (string, bool) {
 
+	if !strings.HasPrefix(name, prefix) {
+		return "", false
+	}
+
+	name = strings.TrimPrefix(name, prefix)
+
+	// just the prefix alone is not enough of a name
+	if name == "" {
+		return "", false
+	}
+
+	// prefix must be followed by an uppercase letter
+	// to be properly camel-cased
+	if !unicode.IsUpper(rune(name[0])) {
+		return "", false
+	}
+
+	return name, true
+
+}
+
+// derefStruct follows pointers and interfaces until
+// reaching the underlying struct value
+func derefStruct(node reflect.Value) (reflect.Value, reflect.Type) {
+
+	nodeType := node.Type()
+	nodeKind := nodeType.Kind()
+	for nodeKind == reflect.Ptr || nodeKind == reflect.Interface {
+		node = node.Elem()
+		nodeType = node.Type()
+		nodeKind = nodeType.Kind()
 	}
+	return node, nodeType
 
 }
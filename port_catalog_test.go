@@ -5,7 +5,11 @@ import (
 	"testing"
 )
 
-type inputTester struct{ BaseNode }
+type inputTester struct {
+	BaseNode
+	InChannel <-chan int
+	InOther   chan<- int
+}
 
 func (*inputTester) InValue(int) {}
 func (*inputTester) InNothing()  {}
@@ -13,7 +17,10 @@ func (*inputTester) InNothing()  {}
 func TestPortCatalog_catalogInPorts(t *testing.T) {
 
 	n := new(inputTester)
-	n.catalogInPorts(reflect.ValueOf(n))
+	err := n.catalogInPorts(reflect.ValueOf(n), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	port := n.In("Value")
 	if port == nil {
@@ -25,6 +32,16 @@ func TestPortCatalog_catalogInPorts(t *testing.T) {
 		t.Error("expected function with no argument not to be cataloged")
 	}
 
+	port = n.In("Channel")
+	if port == nil || n.InChannel == nil {
+		t.Error("expected InChannel field to be cataloged and initialized")
+	}
+
+	port = n.In("Other")
+	if port != nil {
+		t.Error("expected send-only channel field not to be cataloged")
+	}
+
 }
 
 func TestPortCatalog_catalogOutPorts(t *testing.T) {
@@ -39,7 +56,10 @@ func TestPortCatalog_catalogOutPorts(t *testing.T) {
 		OutOther  int           `desc:"not a port but starts with Out"`
 	}{}
 
-	n.catalogOutPorts(reflect.ValueOf(n))
+	err := n.catalogOutPorts(reflect.ValueOf(n), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if len(n.Outs) > 1 {
 		t.Errorf("expected only 1 port to be cataloged, got %d", len(n.Outs))
@@ -61,3 +81,65 @@ func TestPortCatalog_catalogOutPorts(t *testing.T) {
 	}
 
 }
+
+func TestPortCatalog_catalogOutPorts_Existing(t *testing.T) {
+
+	existing := make(chan string, 5)
+	n := &struct {
+		BaseNode
+		OutExisting chan string
+		OutCreated  chan string
+	}{OutExisting: existing}
+
+	err := n.catalogOutPorts(reflect.ValueOf(n), 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n.OutExisting != existing {
+		t.Error("expected pre-initialized channel not to be replaced")
+	}
+	if n.Out("Existing").Owner != NodeOwned {
+		t.Error("expected pre-initialized channel to be node owned")
+	}
+
+	if cap(n.OutCreated) != 2 {
+		t.Errorf("expected created channel to use buffer size 2, got %d", cap(n.OutCreated))
+	}
+	if n.Out("Created").Owner != GraphOwned {
+		t.Error("expected created channel to be graph owned")
+	}
+
+	n.closeOutPorts()
+	select {
+	case existing <- "open":
+	default:
+		t.Error("expected node owned channel to remain open")
+	}
+
+}
+
+func TestPortCatalog_catalogOutPorts_SendOnly(t *testing.T) {
+
+	n := &struct {
+		BaseNode
+		OutValue chan<- string
+	}{OutValue: make(chan string)}
+
+	err := n.catalogOutPorts(reflect.ValueOf(n), 0, nil)
+	if !IsSendOnlyPort(err) {
+		t.Errorf("expected ErrSendOnlyPort for initialized send-only channel, got %v", err)
+	}
+
+}
+
+func TestPortCatalog_catalogInPorts_RecvOnly(t *testing.T) {
+
+	n := &inputTester{InChannel: make(<-chan int)}
+
+	err := n.catalogInPorts(reflect.ValueOf(n), 0, nil)
+	if !IsRecvOnlyPort(err) {
+		t.Errorf("expected ErrRecvOnlyPort for initialized receive-only channel, got %v", err)
+	}
+
+}
@@ -0,0 +1,389 @@
+package churn
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rydrman/churn/churncore"
+
+	"github.com/pkg/errors"
+)
+
+// Replicated is a graph component that runs several instances of
+// the same node type behind a single set of ports. Messages sent to
+// an in port are distributed between the instances, and the messages
+// sent by each instance are merged into the matching out port
+type Replicated struct {
+	BaseNode
+
+	graph   *Graph
+	name    string
+	factory func() Node
+
+	delivery Delivery
+	reorder  bool
+	window   int
+	timeout  time.Duration
+
+	// distributors deliver the messages of each in port to the
+	// replicas, and their envelopes mark the start of the order
+	// that is restored when merging replica results
+	distributors map[string]*churncore.Sender
+	reorders     map[string]*churncore.Reorder
+
+	mutex    sync.Mutex
+	replicas []*replica
+	nextID   int
+
+	// start is closed when the graph is started, after
+	// which the replicas of Runner nodes are run as added
+	start <-chan struct{}
+}
+
+type replica struct {
+	name string
+	node Node
+	// stop cancels the replica's context and waits
+	// for it to stop running, if it is a Runner
+	stop func()
+	// ins are the subscriptions of the replica's in ports to
+	// the distributors, and outs are those of its out ports
+	ins  []*churncore.Subscription
+	outs []*churncore.Subscription
+}
+
+// ReplicaOption is a type that applies one or more options
+// to a new replicated node
+type ReplicaOption interface {
+	Apply(*Replicated)
+}
+
+// ReplicaOptionFunc is a function that can be given as a replica option
+type ReplicaOptionFunc func(*Replicated)
+
+// Apply calls the underlying option function for r
+func (f ReplicaOptionFunc) Apply(r *Replicated) { f(r) }
+
+// Distribute sets how the messages of each in port are distributed
+// between replicas. The default is RoundRobin, and Partition can be
+// used to always send related messages to the same replica. The
+// arguments of join ports should always be partitioned
+func Distribute(delivery Delivery) ReplicaOption {
+	return ReplicaOptionFunc(func(r *Replicated) {
+		r.delivery = delivery
+	})
+}
+
+// RestoreOrder makes each out port send messages in the order of
+// the in port messages that they were derived from, waiting on at
+// most 'window' messages or for 'timeout' when an in port message
+// produces no results. A zero window or timeout disables that limit.
+// Messages are related to the in port message being handled when they
+// were sent, so those sent from a Run goroutine cannot be ordered
+func RestoreOrder(window int, timeout time.Duration) ReplicaOption {
+	return ReplicaOptionFunc(func(r *Replicated) {
+		r.reorder = true
+		r.window = window
+		r.timeout = timeout
+	})
+}
+
+// AddReplicated adds a component to this graph which runs 'n' instances
+// of the node created by 'factory'. Every call to 'factory' must return
+// a new node of the same type
+func (g *Graph) AddReplicated(
+	name string,
+	factory func() Node,
+	n int,
+	options ...ReplicaOption,
+) (*Replicated, error) {
+
+	r := &Replicated{
+		graph:        g,
+		name:         name,
+		factory:      factory,
+		delivery:     RoundRobin(),
+		distributors: make(map[string]*churncore.Sender),
+		reorders:     make(map[string]*churncore.Reorder),
+		start:        g.nextStart(),
+	}
+	for _, option := range options {
+		option.Apply(r)
+	}
+
+	// the ports of a template instance decide the ports of
+	// the replicated node, and it then becomes the first replica
+	template := factory()
+	if err := template.setupBaseNode(template, g, r.replicaName()); err != nil {
+		return nil, errors.Wrap(err, name)
+	}
+	if err := r.catalogReplicaPorts(template); err != nil {
+		return nil, errors.Wrap(err, name)
+	}
+
+	if err := g.Add(name, r); err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err := r.addReplica(template); err != nil {
+		return nil, err
+	}
+	for len(r.replicas) < n {
+		if err := r.addReplica(nil); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+
+}
+
+// Replicas returns the current number of running instances
+func (r *Replicated) Replicas() int {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.replicas)
+
+}
+
+// Scale changes the number of running instances to 'n', which
+// must be at least one. Removed instances are given no more messages,
+// and are closed once they have handled those already queued for them
+func (r *Replicated) Scale(n int) error {
+
+	if n < 1 {
+		return errors.Errorf("%s: cannot scale to %d replicas", r.name, n)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for len(r.replicas) < n {
+		if err := r.addReplica(nil); err != nil {
+			return err
+		}
+	}
+	for len(r.replicas) > n {
+		r.removeReplica()
+	}
+	return nil
+
+}
+
+// nodes returns the node of each replica
+func (r *Replicated) nodes() []Node {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	nodes := make([]Node, 0, len(r.replicas))
+	for _, rep := range r.replicas {
+		nodes = append(nodes, rep.node)
+	}
+	return nodes
+
+}
+
+// setupBaseNode is a no-op since the ports of a replicated
+// node are cataloged from the node that it replicates
+func (r *Replicated) setupBaseNode(Node, *Graph, string) error { return nil }
+
+func (r *Replicated) close() {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for len(r.replicas) > 0 {
+		r.removeReplica()
+	}
+
+}
+
+// catalogReplicaPorts creates the ports of this component
+// to match those of the given node
+func (r *Replicated) catalogReplicaPorts(template Node) error {
+
+	ref := &nodeRef{graph: r.graph, name: r.name}
+	for _, port := range template.catalog().Ins {
+
+		portName := port.Name
+		dataType := port.core.(*churncore.Receiver).DataType()
+		distributor := churncore.NewDirectSender(dataType)
+		distributor.SetScheduler(r.graph.scheduler)
+		distributor.SetSource(func() string { return ref.portPath(portName) })
+		if err := distributor.SetDelivery(r.delivery); err != nil {
+			return errors.Wrap(err, port.Name)
+		}
+		r.distributors[port.Name] = distributor
+
+		r.Ins = append(r.Ins, &Port{
+			Name: port.Name,
+			core: churncore.NewRelayReceiver(dataType, distributor.Send),
+		})
+
+	}
+
+	for _, port := range template.catalog().Outs {
+
+		dataType := port.core.(*churncore.Sender).DataType()
+		merged := churncore.NewDirectSender(dataType)
+		merged.SetScheduler(r.graph.scheduler)
+		if r.reorder {
+			r.reorders[port.Name] = churncore.NewReorder(
+				r.window, r.timeout, r.inputSequence, merged.Forward,
+			)
+		}
+
+		r.Outs = append(r.Outs, &Port{
+			Name: port.Name,
+			core: merged,
+		})
+
+	}
+
+	return nil
+
+}
+
+// inputSequence finds the distributed in port message that the
+// given message was derived from, identifying its order
+func (r *Replicated) inputSequence(env *Envelope) (string, uint64) {
+
+	prefix := BuildGraphPath(r.graph.Path(), r.name, "") + "."
+	for ; env != nil; env = env.Parent {
+		if strings.HasPrefix(env.Source, prefix) {
+			return env.Source, env.Sequence
+		}
+	}
+	return "", 0
+
+}
+
+// replicaName returns the name of the next replica instance
+func (r *Replicated) replicaName() string {
+	return fmt.Sprintf("%s[%d]", r.name, r.nextID)
+}
+
+// addReplica creates and starts a new instance, or uses the given
+// one if not nil. Must be called while holding the mutex
+func (r *Replicated) addReplica(node Node) error {
+
+	name := r.replicaName()
+	if node == nil {
+		node = r.factory()
+		if err := node.setupBaseNode(node, r.graph, name); err != nil {
+			return errors.Wrap(err, r.name)
+		}
+	}
+	r.nextID++
+	ctx, cancel := context.WithCancel(r.graph.ctx)
+	r.graph.bindNode(ctx, name, node)
+	node.Init()
+
+	rep := &replica{name: name, node: node, stop: cancel}
+	for _, port := range node.catalog().Ins {
+		distributor := r.distributors[port.Name]
+		if distributor == nil {
+			continue
+		}
+		// replicas each handle messages in their own goroutine
+		// otherwise there is little point in having them
+		subs, err := distributor.SubscribeWith(
+			port.core.(*churncore.Receiver),
+			churncore.SubscribeOptions{
+				QueueSize: r.graph.queueSize(),
+				OnDrop:    r.dropped,
+			},
+		)
+		if err != nil {
+			rep.close()
+			return errors.Wrap(err, name)
+		}
+		rep.ins = append(rep.ins, subs)
+	}
+
+	for _, out := range r.Outs {
+		port := node.Out(out.Name)
+		if port == nil {
+			continue
+		}
+		sender := port.core.(*churncore.Sender)
+		subs, err := sender.Subscribe(churncore.NewRelayReceiver(
+			sender.DataType(), r.merge(out),
+		))
+		if err != nil {
+			rep.close()
+			return errors.Wrap(err, name)
+		}
+		rep.outs = append(rep.outs, subs)
+	}
+
+	runner, isRunner := node.(Runner)
+	if isRunner {
+		done := r.graph.run(ctx, name, runner, r.start)
+		rep.stop = func() {
+			cancel()
+			<-done
+		}
+	}
+
+	r.replicas = append(r.replicas, rep)
+	r.graph.emit(NodeStarted{Path: BuildGraphPath(r.graph.Path(), name, "")})
+	return nil
+
+}
+
+// merge returns a function that passes replica messages on to
+// the given out port, restoring their order if required
+func (r *Replicated) merge(out *Port) func(*Envelope, reflect.Value) {
+
+	reorder, ok := r.reorders[out.Name]
+	if ok {
+		return reorder.Add
+	}
+	return out.core.(*churncore.Sender).Forward
+
+}
+
+// dropped tells every reorder that a distributed message
+// will never be handled, so its results are not waited on
+func (r *Replicated) dropped(env *Envelope) {
+
+	for _, reorder := range r.reorders {
+		reorder.Drop(env)
+	}
+
+}
+
+// removeReplica stops and closes the newest instance once it has
+// handled its queued messages. Must be called while holding the mutex
+func (r *Replicated) removeReplica() {
+
+	last := len(r.replicas) - 1
+	rep := r.replicas[last]
+	for _, subs := range rep.ins {
+		subs.Drain(r.graph.ctx)
+	}
+	rep.close()
+	r.replicas = r.replicas[:last]
+	if _, isRunner := rep.node.(Runner); !isRunner {
+		r.graph.emit(NodeStopped{Path: BuildGraphPath(r.graph.Path(), rep.name, "")})
+	}
+
+}
+
+func (rep *replica) close() {
+
+	for _, subs := range rep.ins {
+		subs.Close()
+	}
+	rep.stop()
+	rep.node.close()
+	for _, subs := range rep.outs {
+		subs.Close()
+	}
+
+}
@@ -0,0 +1,211 @@
+package churn
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+)
+
+type slowDoubleNode struct{ BaseNode }
+
+func (*slowDoubleNode) InValue(v int64) (int64, error) {
+
+	// later values finish first to scramble the order
+	time.Sleep(time.Duration(10-v) * time.Millisecond)
+	return v * 2, nil
+
+}
+
+type slowChanDoubleNode struct {
+	BaseNode
+	OutValue chan int64
+}
+
+func (n *slowChanDoubleNode) InValue(v int64) {
+
+	time.Sleep(time.Duration(3-v%4) * time.Millisecond)
+	n.OutValue <- v * 2
+
+}
+
+type replicaNameNode struct {
+	BaseNode
+	names chan string
+}
+
+func (n *replicaNameNode) InValue(ctx context.Context, v int64) { n.names <- NodePath(ctx) }
+
+func TestGraph_AddReplicated(t *testing.T) {
+
+	graph := NewGraph()
+	source := new(IntNode)
+	collector := &collectIntNode{values: make(chan int64, 5)}
+	graph.Add("Source", source)
+	graph.Add("Collector", collector)
+	defer graph.Close()
+
+	workers, err := graph.AddReplicated(
+		"Workers",
+		func() Node { return new(slowDoubleNode) },
+		5,
+		RestoreOrder(0, time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if workers.Replicas() != 5 {
+		t.Errorf("expected 5 replicas, got %d", workers.Replicas())
+	}
+
+	graph.Connect("Source.Value", "Workers.Value")
+	graph.Connect("Workers.ValueResult", "Collector.Value")
+
+	for i := int64(1); i <= 5; i++ {
+		source.OutValue <- i
+	}
+
+	for i := int64(1); i <= 5; i++ {
+		select {
+		case actual := <-collector.values:
+			if actual != i*2 {
+				t.Errorf("expected results in input order, got %d at position %d", actual, i)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for replica results")
+		}
+	}
+
+}
+
+func TestGraph_AddReplicated_ChannelPort(t *testing.T) {
+
+	const count = 200
+	graph := NewGraph()
+	source := new(IntNode)
+	collector := &collectIntNode{values: make(chan int64, count)}
+	graph.Add("Source", source)
+	graph.Add("Collector", collector)
+	defer graph.Close()
+
+	_, err := graph.AddReplicated(
+		"Workers",
+		func() Node { return new(slowChanDoubleNode) },
+		4,
+		RestoreOrder(0, 200*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph.Connect("Source.Value", "Workers.Value")
+	graph.Connect("Workers.Value", "Collector.Value")
+
+	// values written to a channel out port are ordered
+	// just like the results of a functional port
+	go func() {
+		for i := int64(0); i < count; i++ {
+			source.OutValue <- i
+		}
+	}()
+	for i := int64(0); i < count; i++ {
+		select {
+		case actual := <-collector.values:
+			if actual != i*2 {
+				t.Fatalf("expected results in input order, got %d at position %d", actual, i)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for replica result %d", i)
+		}
+	}
+
+}
+
+func TestReplicated_Scale(t *testing.T) {
+
+	graph := NewGraph()
+	source := new(IntNode)
+	names := make(chan string, 4)
+	graph.Add("Source", source)
+	defer graph.Close()
+
+	workers, err := graph.AddReplicated(
+		"Workers",
+		func() Node { return &replicaNameNode{names: names} },
+		1,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph.Connect("Source.Value", "Workers.Value")
+
+	if err = workers.Scale(2); err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(0); i < 2; i++ {
+		source.OutValue <- i
+	}
+	actual := []string{<-names, <-names}
+	sort.Strings(actual)
+	if actual[0] != "Workers[0]" || actual[1] != "Workers[1]" {
+		t.Errorf("expected messages to be distributed to both replicas, got %v", actual)
+	}
+
+	workers.Scale(1)
+	for i := int64(0); i < 2; i++ {
+		source.OutValue <- i
+		if actual := <-names; actual != "Workers[0]" {
+			t.Errorf("expected only the remaining replica to receive messages, got %q", actual)
+		}
+	}
+
+	if err = workers.Scale(0); err == nil {
+		t.Error("expected scaling to zero replicas to fail")
+	}
+
+}
+
+func TestReplicated_ScaleDown(t *testing.T) {
+
+	graph := NewGraph()
+	source := new(IntNode)
+	collector := &collectIntNode{values: make(chan int64, 6)}
+	graph.Add("Source", source)
+	graph.Add("Collector", collector)
+	defer graph.Close()
+
+	// without a timeout, any message lost by the removed
+	// replica would hold back every later result
+	workers, err := graph.AddReplicated(
+		"Workers",
+		func() Node { return new(slowDoubleNode) },
+		2,
+		RestoreOrder(0, 0),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph.Connect("Source.Value", "Workers.Value")
+	graph.Connect("Workers.ValueResult", "Collector.Value")
+
+	for i := int64(1); i <= 4; i++ {
+		source.OutValue <- i
+	}
+	if err = workers.Scale(1); err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(5); i <= 6; i++ {
+		source.OutValue <- i
+	}
+
+	for i := int64(1); i <= 6; i++ {
+		select {
+		case actual := <-collector.values:
+			if actual != i*2 {
+				t.Errorf("expected results in input order, got %d at position %d", actual, i)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for replica results")
+		}
+	}
+
+}
@@ -0,0 +1,77 @@
+package churn
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/rydrman/churn/churncore"
+)
+
+// Deterministic makes a graph deliver its messages from a single
+// scheduler rather than from the goroutine of each sender. Messages
+// only move when the graph is stepped, and are handled in the order
+// that they were sent, so that a graph run the same way will always
+// handle its messages in the same order. Nodes that run, or that
+// have channel in ports, still do so in their own goroutines
+func Deterministic() GraphOption {
+	return OptionFunc(func(g *Graph) {
+		g.scheduler = churncore.NewScheduler(nil)
+	})
+}
+
+// RandomSchedule makes a graph deterministic, but handles messages in
+// a random order chosen by 'seed'. Messages passing through any one
+// connection are still handled in the order that they were sent.
+// Different seeds can be used to explore alternative interleavings
+// that may arise when the graph is run normally
+func RandomSchedule(seed int64) GraphOption {
+	return OptionFunc(func(g *Graph) {
+		g.scheduler = churncore.NewScheduler(rand.New(rand.NewSource(seed)))
+	})
+}
+
+// Step handles a single pending message in a deterministic graph or
+// its sub-graphs, returning false if there are none. The message is
+// handled in the calling goroutine. Sub-graphs are only stepped once
+// the graph itself has no pending messages, in order of their names
+func (g *Graph) Step() bool {
+
+	if g.scheduler != nil && g.scheduler.Step() {
+		return true
+	}
+
+	g.componentMutex.Lock()
+	var names []string
+	for name, cmpt := range g.components {
+		if _, ok := cmpt.(*Graph); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	subGraphs := make([]*Graph, 0, len(names))
+	for _, name := range names {
+		subGraphs = append(subGraphs, g.components[name].(*Graph))
+	}
+	g.componentMutex.Unlock()
+
+	for _, subGraph := range subGraphs {
+		if subGraph.Step() {
+			return true
+		}
+	}
+	return false
+
+}
+
+// Settle steps a deterministic graph until there are no pending
+// messages, returning the number of messages that were handled.
+// A graph with a cycle that never stops sending will not settle
+func (g *Graph) Settle() int {
+
+	count := 0
+	for g.Step() {
+		count++
+	}
+	return count
+
+}
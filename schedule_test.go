@@ -0,0 +1,97 @@
+package churn
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type recordNode struct {
+	BaseNode
+	values []string
+}
+
+func (n *recordNode) InValue(v string) { n.values = append(n.values, v) }
+
+func TestGraph_Deterministic(t *testing.T) {
+
+	graph := NewGraph(Deterministic())
+	source := new(IntNode)
+	collector := &collectIntNode{values: make(chan int64, 3)}
+	graph.Add("Source", source)
+	graph.Add("Double", new(doubleNode))
+	graph.Add("Collector", collector)
+	defer graph.Close()
+
+	graph.Connect("Source.Value", "Double.Value")
+	graph.Connect("Double.ValueResult", "Collector.Value")
+	for i := int64(1); i <= 3; i++ {
+		source.OutValue <- i
+	}
+
+	if !graph.Step() {
+		t.Fatal("expected a pending message")
+	}
+	if len(collector.values) != 0 {
+		t.Error("expected messages not to move until stepped")
+	}
+
+	if count := graph.Settle(); count != 5 {
+		t.Errorf("expected 5 more messages to be handled, got %d", count)
+	}
+	for _, expected := range []int64{2, 4, 6} {
+		if actual := <-collector.values; actual != expected {
+			t.Errorf("expected %d, got %d", expected, actual)
+		}
+	}
+	if graph.Step() {
+		t.Error("expected no pending messages once settled")
+	}
+
+}
+
+func TestGraph_RandomSchedule(t *testing.T) {
+
+	run := func(seed int64) []string {
+		graph := NewGraph(RandomSchedule(seed))
+		a, b := new(StringNode), new(StringNode)
+		recorder := new(recordNode)
+		graph.Add("A", a)
+		graph.Add("B", b)
+		graph.Add("Recorder", recorder)
+		defer graph.Close()
+
+		graph.Connect("A.Value", "Recorder.Value")
+		graph.Connect("B.Value", "Recorder.Value")
+		for _, v := range []string{"1", "2", "3"} {
+			a.OutValue <- "a" + v
+			b.OutValue <- "b" + v
+		}
+		graph.Settle()
+		return recorder.values
+	}
+
+	first := run(1)
+	if second := run(1); !reflect.DeepEqual(first, second) {
+		t.Errorf("expected the same seed to give the same order, got %v and %v", first, second)
+	}
+
+	orders := make(map[string]bool)
+	for seed := int64(1); seed <= 20; seed++ {
+		values := run(seed)
+		var fromA []string
+		for _, v := range values {
+			if strings.HasPrefix(v, "a") {
+				fromA = append(fromA, v)
+			}
+		}
+		if expected := []string{"a1", "a2", "a3"}; !reflect.DeepEqual(fromA, expected) {
+			t.Errorf("expected each connection to stay in order, got %v", values)
+		}
+		orders[strings.Join(values, ",")] = true
+	}
+	if len(orders) < 2 {
+		t.Error("expected different seeds to give different orders")
+	}
+
+}
@@ -0,0 +1,149 @@
+package churn
+
+import (
+	"sort"
+	"time"
+
+	"github.com/rydrman/churn/churncore"
+)
+
+// Histogram is a snapshot of a distribution of durations
+type Histogram = churncore.Histogram
+
+// Stats is a snapshot of the activity in a graph network
+type Stats struct {
+	Ports       []PortStats
+	Handlers    []HandlerStats
+	Connections []ConnectionStats
+}
+
+// PortStats describes the activity of a single port
+type PortStats struct {
+	// Path is the full graph path of the port
+	Path string
+	// Direction is either "in" or "out"
+	Direction string
+	// Messages is the number of messages sent by an out
+	// port, or received by an in port
+	Messages uint64
+}
+
+// HandlerStats describes the calls made to the handler function
+// of an in port. The arguments of a join share a single handler,
+// whose path is that of the join without an argument name
+type HandlerStats struct {
+	Path string
+	churncore.HandlerStats
+}
+
+// ConnectionStats describes the activity of a single connection
+type ConnectionStats struct {
+	// Source and Dest are the full graph paths
+	// of the connected ports
+	Source string
+	Dest   string
+	// Delivered is the number of messages given to the in
+	// port, which excludes any dropped by filters
+	Delivered uint64
+	// QueueDepth is the number of messages waiting
+	// to be handled by the in port
+	QueueDepth int
+	// Blocked is the total time that the out port has spent
+	// waiting on this connection, including the time spent
+	// handling messages that are delivered directly
+	Blocked time.Duration
+}
+
+// Stats returns a snapshot of the activity of every port,
+// handler and connection in this graph and its sub-graphs
+func (g *Graph) Stats() Stats {
+
+	var stats Stats
+	g.collectStats(g.Path(), &stats)
+
+	sort.Slice(stats.Ports, func(i, j int) bool {
+		a, b := stats.Ports[i], stats.Ports[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Direction < b.Direction
+	})
+	sort.Slice(stats.Handlers, func(i, j int) bool {
+		return stats.Handlers[i].Path < stats.Handlers[j].Path
+	})
+	sort.Slice(stats.Connections, func(i, j int) bool {
+		a, b := stats.Connections[i], stats.Connections[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		return a.Dest < b.Dest
+	})
+	return stats
+
+}
+
+func (g *Graph) collectStats(prefix string, stats *Stats) {
+
+	g.componentMutex.Lock()
+	defer g.componentMutex.Unlock()
+
+	for _, conn := range g.connections {
+		subs := conn.subscription
+		stats.Connections = append(stats.Connections, ConnectionStats{
+			Source:     fullPortPath(prefix, conn.Source),
+			Dest:       fullPortPath(prefix, conn.Dest),
+			Delivered:  subs.Delivered(),
+			QueueDepth: subs.Load(),
+			Blocked:    subs.BlockedTime(),
+		})
+	}
+
+	for name, cmpt := range g.components {
+		switch cmpt := cmpt.(type) {
+		case *Graph:
+			cmpt.collectStats(BuildGraphPath(prefix, name, ""), stats)
+		case Node:
+			collectNodeStats(BuildGraphPath(prefix, name, ""), cmpt.catalog(), stats)
+		}
+	}
+
+}
+
+func collectNodeStats(nodePath string, catalog *PortCatalog, stats *Stats) {
+
+	handled := make(map[string]bool)
+	for _, port := range catalog.Ins {
+		receiver, ok := port.core.(*churncore.Receiver)
+		if !ok {
+			continue
+		}
+		stats.Ports = append(stats.Ports, PortStats{
+			Path:      BuildGraphPath(nodePath, "", port.Name),
+			Direction: "in",
+			Messages:  receiver.Received(),
+		})
+
+		name := handlerName(port.Name)
+		if receiver.IsRelay() || handled[name] {
+			continue
+		}
+		handled[name] = true
+		stats.Handlers = append(stats.Handlers, HandlerStats{
+			Path:         BuildGraphPath(nodePath, "", name),
+			HandlerStats: receiver.Stats(),
+		})
+	}
+
+	for _, port := range catalog.Outs {
+		sender, ok := port.core.(*churncore.Sender)
+		if !ok {
+			continue
+		}
+		stats.Ports = append(stats.Ports, PortStats{
+			Path:      BuildGraphPath(nodePath, "", port.Name),
+			Direction: "out",
+			Messages:  sender.Sent(),
+		})
+	}
+
+}
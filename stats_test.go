@@ -0,0 +1,48 @@
+package churn
+
+import (
+	"testing"
+)
+
+func TestGraph_Stats(t *testing.T) {
+
+	graph := NewGraph(Deterministic())
+	a, b := new(FloatNode), new(FloatNode)
+	graph.Add("A", a)
+	graph.Add("B", b)
+	graph.Add("Add", new(addNode))
+	defer graph.Close()
+	graph.Connect("A.Value", "Add.Sum:a")
+	graph.Connect("B.Value", "Add.Sum:b")
+
+	a.OutValue <- 1
+	b.OutValue <- 2
+	b.OutValue <- 3
+	graph.Settle()
+
+	stats := graph.Stats()
+	if len(stats.Handlers) != 1 {
+		t.Fatalf("expected join arguments to share one handler, got %+v", stats.Handlers)
+	}
+	handler := stats.Handlers[0]
+	if handler.Path != "Add.Sum" || handler.Calls != 2 || handler.Latency.Count != 2 {
+		t.Errorf("expected two calls to Add.Sum, got %+v", handler)
+	}
+
+	messages := make(map[string]uint64)
+	for _, port := range stats.Ports {
+		messages[port.Path+" "+port.Direction] = port.Messages
+	}
+	if messages["B.Value out"] != 2 || messages["Add.Sum:b in"] != 2 || messages["Add.SumResult out"] != 2 {
+		t.Errorf("unexpected port message counts %v", messages)
+	}
+
+	if len(stats.Connections) != 2 {
+		t.Fatalf("expected stats for both connections, got %+v", stats.Connections)
+	}
+	conn := stats.Connections[1]
+	if conn.Source != "B.Value" || conn.Dest != "Add.Sum:b" || conn.Delivered != 2 || conn.QueueDepth != 0 {
+		t.Errorf("unexpected connection stats %+v", conn)
+	}
+
+}
@@ -0,0 +1,166 @@
+package churn
+
+import (
+	"iter"
+	"reflect"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// TapOption is a type that applies one or more options to a tap
+type TapOption interface {
+	Apply(*tapConfig)
+}
+
+// TapOptionFunc is a function that can be given as a tap option
+type TapOptionFunc func(*tapConfig)
+
+// Apply calls the underlying option function for c
+func (f TapOptionFunc) Apply(c *tapConfig) { f(c) }
+
+type tapConfig struct {
+	buffer int
+	drop   bool
+}
+
+// TapBuffer sets the buffer size of the channel returned by a tap,
+// which is unbuffered by default
+func TapBuffer(size int) TapOption {
+	return TapOptionFunc(func(c *tapConfig) {
+		c.buffer = size
+	})
+}
+
+// TapDrop makes a tap discard values when its channel is not ready
+// to receive them, rather than blocking the out port until it is
+func TapDrop() TapOption {
+	return TapOptionFunc(func(c *tapConfig) {
+		c.drop = true
+	})
+}
+
+// Tap returns a channel that receives every value sent from the given
+// out port, alongside any connections that the port has. By default
+// the out port is blocked until each value is received. The channel is
+// closed when the returned cancel function is called, or the graph
+// is stopped
+func (g *Graph) Tap(portPath string, options ...TapOption) (<-chan interface{}, func(), error) {
+	return tap[interface{}](g, portPath, options)
+}
+
+// TapTyped is like Graph.Tap, but receives values as type 'T'.
+// The port's values must be assignable to 'T'
+func TapTyped[T any](g *Graph, portPath string, options ...TapOption) (<-chan T, func(), error) {
+	return tap[T](g, portPath, options)
+}
+
+// TapSeq returns a sequence of the values sent from the given out port
+// after iteration starts. Each iteration taps the port separately, and
+// ends when the loop is broken or the graph is stopped
+func TapSeq[T any](g *Graph, portPath string, options ...TapOption) (iter.Seq[T], error) {
+
+	if err := checkTap[T](g, portPath); err != nil {
+		return nil, err
+	}
+
+	return func(yield func(T) bool) {
+		values, cancel, err := tap[T](g, portPath, options)
+		if err != nil {
+			return
+		}
+		defer cancel()
+		for value := range values {
+			if !yield(value) {
+				return
+			}
+		}
+	}, nil
+
+}
+
+// checkTap validates that the given out port exists and
+// that its values can be received as type 'T'
+func checkTap[T any](g *Graph, portPath string) error {
+
+	port := g.GetOutPort(portPath)
+	if port == nil {
+		return errors.Wrap(ErrPortNotExist, portPath)
+	}
+	target := reflect.TypeOf((*T)(nil)).Elem()
+	if !port.DataType().AssignableTo(target) {
+		return errors.Wrapf(
+			ErrTypeMismatch, "%s: cannot receive [%s] as [%s]",
+			portPath, port.DataType(), target,
+		)
+	}
+	return nil
+
+}
+
+func tap[T any](g *Graph, portPath string, options []TapOption) (<-chan T, func(), error) {
+
+	if err := checkTap[T](g, portPath); err != nil {
+		return nil, nil, err
+	}
+
+	config := new(tapConfig)
+	for _, option := range options {
+		option.Apply(config)
+	}
+
+	values := make(chan T, config.buffer)
+	done := make(chan struct{})
+
+	// lock is held for reading while sending on the channel,
+	// so that it is not closed until all sends have returned
+	var (
+		lock   sync.RWMutex
+		closed bool
+	)
+	send := func(value interface{}) {
+		v, _ := value.(T)
+		lock.RLock()
+		defer lock.RUnlock()
+		if closed {
+			return
+		}
+		if config.drop {
+			select {
+			case values <- v:
+			default:
+			}
+			return
+		}
+		select {
+		case values <- v:
+		case <-done:
+		}
+	}
+
+	stopObserving, err := g.Observe(portPath, send)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			stopObserving()
+			close(done)
+			lock.Lock()
+			closed = true
+			close(values)
+			lock.Unlock()
+		})
+	}
+	go func() {
+		select {
+		case <-g.ctx.Done():
+			cancel()
+		case <-done:
+		}
+	}()
+	return values, cancel, nil
+
+}
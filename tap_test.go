@@ -0,0 +1,110 @@
+package churn
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGraph_Tap(t *testing.T) {
+
+	graph := NewGraph()
+	source := new(IntNode)
+	graph.Add("Source", source)
+	graph.Add("Double", new(doubleNode))
+	graph.Connect("Source.Value", "Double.Value")
+
+	values, cancel, err := graph.Tap("Double.ValueResult")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+
+	go func() { source.OutValue <- 2 }()
+	if actual := <-values; actual != int64(4) {
+		t.Errorf("expected 4, got %v", actual)
+	}
+
+	graph.Stop()
+	select {
+	case _, ok := <-values:
+		if ok {
+			t.Error("expected no more values")
+		}
+	case <-time.After(time.Second):
+		t.Error("expected tap to close when the graph is stopped")
+	}
+	graph.Close()
+
+	if _, _, err := graph.Tap("Double.Missing"); !IsPortNotExist(err) {
+		t.Errorf("expected missing port error, got %v", err)
+	}
+
+}
+
+func TestTapTyped(t *testing.T) {
+
+	graph := NewGraph()
+	source := new(IntNode)
+	graph.Add("Source", source)
+	defer graph.Close()
+
+	values, cancel, err := TapTyped[int64](graph, "Source.Value", TapBuffer(1), TapDrop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+
+	// the second value is dropped since the buffer is full
+	source.OutValue <- 1
+	source.OutValue <- 2
+	source.OutValue <- 3
+	if actual := <-values; actual != 1 {
+		t.Errorf("expected 1, got %d", actual)
+	}
+
+	if _, _, err := TapTyped[string](graph, "Source.Value"); !IsTypeMismatch(err) {
+		t.Errorf("expected type mismatch error, got %v", err)
+	}
+
+}
+
+func TestTapSeq(t *testing.T) {
+
+	graph := NewGraph()
+	source := new(IntNode)
+	graph.Add("Source", source)
+	defer graph.Close()
+
+	seq, err := TapSeq[int64](graph, "Source.Value")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for i := int64(1); ; i++ {
+			select {
+			case source.OutValue <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	defer func() {
+		close(stop)
+		<-stopped
+	}()
+
+	var sum int64
+	for v := range seq {
+		sum += v
+		if v == 3 {
+			break
+		}
+	}
+	if sum != 6 {
+		t.Errorf("expected to sum the first three values, got %d", sum)
+	}
+
+}
@@ -0,0 +1,63 @@
+package churn
+
+import (
+	"github.com/rydrman/churn/churncore"
+)
+
+// TraceParentHeader is the message header that links each
+// traced handler call to the call that sent its message
+const TraceParentHeader = churncore.TraceParentHeader
+
+// Span records a single call to an in port handler
+type Span = churncore.Span
+
+// SpanExporter receives the span of each traced handler call
+// as it ends. Spans may be exported from many goroutines at once
+type SpanExporter interface {
+	ExportSpan(*Span)
+}
+
+// SpanExporterFunc is a function that can be used as a span exporter
+type SpanExporterFunc func(*Span)
+
+// ExportSpan calls the underlying exporter function with s
+func (f SpanExporterFunc) ExportSpan(s *Span) { f(s) }
+
+// Tracing records a span for every call made to an in port handler
+// in the graph network, and passes them to 'exporter'. Sub-graphs
+// use the exporter of the closest graph that has one
+func Tracing(exporter SpanExporter) GraphOption {
+	return OptionFunc(func(g *Graph) {
+		g.exporter = exporter
+	})
+}
+
+// spanExporter returns the exporter of the closest
+// graph that has one, starting with this one
+func (g *Graph) spanExporter() SpanExporter {
+
+	for ; g != nil; g = g.parent {
+		if g.exporter != nil {
+			return g.exporter
+		}
+	}
+	return nil
+
+}
+
+// tracer returns a function that provides the recorder of
+// spans for the named handler, if the graph is traced
+func (g *Graph) tracer(ref *nodeRef, portName string) func() func(*Span) {
+
+	return func() func(*Span) {
+		exporter := g.spanExporter()
+		if exporter == nil {
+			return nil
+		}
+		return func(span *Span) {
+			span.Name = ref.portPath(portName)
+			exporter.ExportSpan(span)
+		}
+	}
+
+}
@@ -0,0 +1,71 @@
+package churn
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+type upperNode struct{ BaseNode }
+
+func (*upperNode) InValue(s string) (string, error) {
+	if s == "" {
+		return "", errors.New("empty")
+	}
+	return strings.ToUpper(s), nil
+}
+
+type spanRecorder struct {
+	mutex sync.Mutex
+	spans []*Span
+}
+
+func (r *spanRecorder) ExportSpan(s *Span) {
+	r.mutex.Lock()
+	r.spans = append(r.spans, s)
+	r.mutex.Unlock()
+}
+
+func TestGraph_Tracing(t *testing.T) {
+
+	spans := new(spanRecorder)
+	graph := NewGraph(Deterministic(), Tracing(spans), ErrorHandler(func(error) {}))
+	source := new(StringNode)
+	sub := NewGraph()
+	graph.Add("Source", source)
+	graph.Add("Upper", new(upperNode))
+	graph.Add("Sub", sub)
+	sub.Add("Print", new(PrintNode))
+	defer graph.Close()
+	graph.Connect("Source.Value", "Upper.Value")
+	graph.Connect("Upper.ValueResult", "Sub/Print.Message")
+
+	source.OutValue <- "hello"
+	graph.Settle()
+
+	if len(spans.spans) != 2 {
+		t.Fatalf("expected a span for each handler call, got %d", len(spans.spans))
+	}
+	upper, printed := spans.spans[0], spans.spans[1]
+	if upper.Name != "Upper.Value" || printed.Name != "Sub/Print.Message" {
+		t.Errorf("expected spans to be named by port path, got %q and %q", upper.Name, printed.Name)
+	}
+	if upper.ParentSpanID != "" {
+		t.Errorf("expected the first span to have no parent, got %q", upper.ParentSpanID)
+	}
+	if printed.TraceID != upper.TraceID || printed.ParentSpanID != upper.SpanID {
+		t.Errorf("expected the second span to be a child of the first, got %+v and %+v", upper, printed)
+	}
+	if upper.End.Before(upper.Start) || upper.Attributes["churn.source"] != "Source.Value" {
+		t.Errorf("unexpected span %+v", upper)
+	}
+
+	source.OutValue <- ""
+	graph.Settle()
+	if len(spans.spans) != 3 || spans.spans[2].Err == nil {
+		t.Errorf("expected the error of the failed call to be recorded")
+	}
+
+}
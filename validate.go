@@ -0,0 +1,103 @@
+package churn
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Validate checks the graph network for problems that would stop
+// it from running correctly, which is currently any cycle of
+// connections that does not pass through a delayed connection
+func (g *Graph) Validate() error {
+
+	edges := make(map[string][]string)
+	g.collectEdges("", edges)
+
+	cycle := findCycle(edges)
+	if cycle != nil {
+		return errors.Wrap(ErrCycle, strings.Join(cycle, " -> "))
+	}
+	return nil
+
+}
+
+// collectEdges records a link between the nodes of every undelayed
+// connection in this graph and its sub-graphs, where 'prefix' is
+// the path of this graph
+func (g *Graph) collectEdges(prefix string, edges map[string][]string) {
+
+	g.componentMutex.Lock()
+	defer g.componentMutex.Unlock()
+
+	for _, conn := range g.connections {
+		if conn.Delayed {
+			continue
+		}
+		src := connectedNode(prefix, conn.Source)
+		dest := connectedNode(prefix, conn.Dest)
+		edges[src] = append(edges[src], dest)
+	}
+
+	for name, cmpt := range g.components {
+		if subGraph, ok := cmpt.(*Graph); ok {
+			subGraph.collectEdges(BuildGraphPath(prefix, name, ""), edges)
+		}
+	}
+
+}
+
+// connectedNode returns the full path of the node that
+// owns a port within the graph at 'prefix'
+func connectedNode(prefix, portPath string) string {
+
+	location, node, _ := SplitGraphPath(portPath)
+	return BuildGraphPath(path.Join(prefix, location), node, "")
+
+}
+
+// findCycle returns the first cycle found between the given
+// nodes and the nodes that they link to, or nil if there is none
+func findCycle(edges map[string][]string) []string {
+
+	nodes := make([]string, 0, len(edges))
+	for node := range edges {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	visited := make(map[string]bool)
+	var stack []string
+	var visit func(node string) []string
+	visit = func(node string) []string {
+
+		for i, n := range stack {
+			if n == node {
+				return append(stack[i:len(stack):len(stack)], node)
+			}
+		}
+		if visited[node] {
+			return nil
+		}
+		visited[node] = true
+		stack = append(stack, node)
+		for _, next := range edges[node] {
+			if cycle := visit(next); cycle != nil {
+				return cycle
+			}
+		}
+		stack = stack[:len(stack)-1]
+		return nil
+
+	}
+
+	for _, node := range nodes {
+		if cycle := visit(node); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+
+}
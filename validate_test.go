@@ -0,0 +1,81 @@
+package churn
+
+import (
+	"testing"
+	"time"
+)
+
+type loopNode struct {
+	BaseNode
+	OutNext chan int64
+	seen    chan int64
+}
+
+func (n *loopNode) InValue(v int64) {
+	n.seen <- v
+	if v < 3 {
+		n.OutNext <- v + 1
+	}
+}
+
+func TestGraph_Validate(t *testing.T) {
+
+	graph := NewGraph()
+	sub := NewGraph()
+	graph.Add("Sub", sub)
+	graph.Add("A", new(relayNode))
+	sub.Add("B", new(relayNode))
+	defer graph.Close()
+
+	if err := graph.Connect("A.Value", "Sub/B.Value"); err != nil {
+		t.Fatal(err)
+	}
+	if err := graph.Validate(); err != nil {
+		t.Errorf("expected no error without a cycle, got %v", err)
+	}
+
+	if err := graph.Connect("Sub/B.Value", "A.Value"); err != nil {
+		t.Fatal(err)
+	}
+	err := graph.Validate()
+	if !IsCycle(err) {
+		t.Fatalf("expected cycle error, got %v", err)
+	}
+	if expected := "A -> Sub/B -> A: " + ErrCycle.Error(); err.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, err.Error())
+	}
+
+}
+
+func TestGraph_Connect_Delay(t *testing.T) {
+
+	graph := NewGraph()
+	source := new(IntNode)
+	loop := &loopNode{seen: make(chan int64, 4)}
+	graph.Add("Source", source)
+	graph.Add("Loop", loop)
+	defer graph.Close()
+
+	if err := graph.Connect("Source.Value", "Loop.Value"); err != nil {
+		t.Fatal(err)
+	}
+	if err := graph.Connect("Loop.Next", "Loop.Value", Delay()); err != nil {
+		t.Fatal(err)
+	}
+	if err := graph.Validate(); err != nil {
+		t.Fatalf("expected delayed cycle to be valid, got %v", err)
+	}
+
+	source.OutValue <- 0
+	for expected := int64(0); expected <= 3; expected++ {
+		select {
+		case actual := <-loop.seen:
+			if actual != expected {
+				t.Errorf("expected %d, got %d", expected, actual)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for feedback loop")
+		}
+	}
+
+}
@@ -0,0 +1,237 @@
+package churn
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rydrman/churn/churncore"
+)
+
+// StallReport describes ports that have been blocked for longer
+// than the watchdog threshold. It is also an error whose cause
+// is ErrStalled, for reporting to the graph error handler
+type StallReport struct {
+	// Blocked lists every port that was blocked at the time
+	// of the report, ordered by path
+	Blocked []BlockedPort
+
+	// Cycle is the path of each node in a group of nodes that
+	// are all waiting on one another, if any, with the first
+	// node repeated at the end
+	Cycle []string
+
+	// Stacks is a dump of every goroutine at the time of the report
+	Stacks string
+}
+
+// BlockedPort describes a single port that has stopped making progress
+type BlockedPort struct {
+	// Port is the full path of the blocked port. This is an out port
+	// when a connection is not accepting messages, or an in port when
+	// its handler has not returned
+	Port string
+
+	// WaitingOn is the full path of the in port that an out port is
+	// waiting to deliver to, or empty for in ports
+	WaitingOn string
+
+	// Duration is how long the port has been blocked
+	Duration time.Duration
+}
+
+// Watchdog checks the graph network for ports that have been blocked
+// for longer than 'threshold', and for nodes that are waiting on one
+// another in a cycle. Each time a new port becomes blocked a report
+// is passed to 'onStall', or to the graph error handler if nil. A
+// threshold of zero or less disables the watchdog. When sub-graphs
+// also have a watchdog, only the outermost one reports their stalls
+func Watchdog(threshold time.Duration, onStall func(*StallReport)) GraphOption {
+	return OptionFunc(func(g *Graph) {
+		g.watchdog = threshold
+		g.onStall = onStall
+	})
+}
+
+// Error summarizes the blocked ports and cycle of the report
+func (r *StallReport) Error() string {
+
+	ports := make([]string, 0, len(r.Blocked))
+	for _, blocked := range r.Blocked {
+		desc := fmt.Sprintf("%s (%s)", blocked.Port, blocked.Duration.Round(time.Millisecond))
+		if blocked.WaitingOn != "" {
+			desc = fmt.Sprintf("%s -> %s", desc, blocked.WaitingOn)
+		}
+		ports = append(ports, desc)
+	}
+	msg := fmt.Sprintf("%s: %s", ErrStalled, strings.Join(ports, ", "))
+	if len(r.Cycle) > 0 {
+		msg = fmt.Sprintf("%s; deadlocked: %s", msg, strings.Join(r.Cycle, " -> "))
+	}
+	return msg
+
+}
+
+// minWatchdogInterval is the shortest time between checks for stalls
+const minWatchdogInterval = time.Millisecond
+
+// Cause returns ErrStalled
+func (r *StallReport) Cause() error { return ErrStalled }
+
+// watch periodically checks for stalls until the graph is stopped
+func (g *Graph) watch() {
+
+	interval := g.watchdog / 2
+	if interval < minWatchdogInterval {
+		interval = minWatchdogInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	reported := make(map[string]bool)
+	for {
+		select {
+		case <-g.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if g.watchedByParent() {
+			continue
+		}
+
+		report := g.checkStalls(g.watchdog)
+		current := make(map[string]bool)
+		isNew := false
+		for _, blocked := range report.Blocked {
+			key := blocked.Port + ">" + blocked.WaitingOn
+			current[key] = true
+			isNew = isNew || !reported[key]
+		}
+		reported = current
+		if !isNew {
+			continue
+		}
+
+		report.Stacks = goroutineStacks()
+		if g.onStall != nil {
+			g.onStall(report)
+		} else {
+			g.reportError(report)
+		}
+	}
+
+}
+
+// watchedByParent returns true if any graph that this one
+// is nested in has a watchdog, which also checks this graph
+func (g *Graph) watchedByParent() bool {
+
+	for graph := g; ; {
+		graph.componentMutex.Lock()
+		parent := graph.parent
+		graph.componentMutex.Unlock()
+		if parent == nil {
+			return false
+		}
+		if parent.watchdog > 0 {
+			return true
+		}
+		graph = parent
+	}
+
+}
+
+// checkStalls finds every port in this graph and its sub-graphs
+// that has been blocked for at least 'threshold'
+func (g *Graph) checkStalls(threshold time.Duration) *StallReport {
+
+	report := new(StallReport)
+	waits := make(map[string][]string)
+	g.collectStalls(g.Path(), threshold, report, waits)
+
+	sort.Slice(report.Blocked, func(i, j int) bool {
+		a, b := report.Blocked[i], report.Blocked[j]
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		return a.WaitingOn < b.WaitingOn
+	})
+	report.Cycle = findCycle(waits)
+	return report
+
+}
+
+func (g *Graph) collectStalls(
+	prefix string,
+	threshold time.Duration,
+	report *StallReport,
+	waits map[string][]string,
+) {
+
+	g.componentMutex.Lock()
+	defer g.componentMutex.Unlock()
+
+	for _, conn := range g.connections {
+		stalled := conn.subscription.Stalled()
+		if stalled < threshold {
+			continue
+		}
+		report.Blocked = append(report.Blocked, BlockedPort{
+			Port:      fullPortPath(prefix, conn.Source),
+			WaitingOn: fullPortPath(prefix, conn.Dest),
+			Duration:  stalled,
+		})
+		src := connectedNode(prefix, conn.Source)
+		waits[src] = append(waits[src], connectedNode(prefix, conn.Dest))
+	}
+
+	for name, cmpt := range g.components {
+		switch cmpt := cmpt.(type) {
+		case *Graph:
+			cmpt.collectStalls(BuildGraphPath(prefix, name, ""), threshold, report, waits)
+		case Node:
+			for _, port := range cmpt.catalog().Ins {
+				receiver, ok := port.core.(*churncore.Receiver)
+				if !ok {
+					continue
+				}
+				stalled := receiver.Stalled()
+				if stalled < threshold {
+					continue
+				}
+				report.Blocked = append(report.Blocked, BlockedPort{
+					Port:     BuildGraphPath(prefix, name, port.Name),
+					Duration: stalled,
+				})
+			}
+		}
+	}
+
+}
+
+// fullPortPath returns the full path of a port given
+// relative to the graph at 'prefix'
+func fullPortPath(prefix, portPath string) string {
+
+	location, node, port := SplitGraphPath(portPath)
+	return BuildGraphPath(path.Join(prefix, location), node, port)
+
+}
+
+// goroutineStacks returns the stack traces of all goroutines
+func goroutineStacks() string {
+
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(bytes.TrimSpace(buf[:n]))
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+
+}
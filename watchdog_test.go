@@ -0,0 +1,132 @@
+package churn
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type echoNode struct {
+	BaseNode
+	OutNext chan int64
+	stop    chan struct{}
+}
+
+func (n *echoNode) InValue(v int64) {
+	select {
+	case n.OutNext <- v + 1:
+	case <-n.stop:
+	}
+}
+
+type blockNode struct {
+	BaseNode
+	stop chan struct{}
+}
+
+func (n *blockNode) InValue(v int64) { <-n.stop }
+
+func TestGraph_Watchdog(t *testing.T) {
+
+	reports := make(chan *StallReport, 10)
+	graph := NewGraph(Watchdog(20*time.Millisecond, func(r *StallReport) { reports <- r }))
+	source := new(IntNode)
+	echo := &echoNode{stop: make(chan struct{})}
+	graph.Add("Source", source)
+	graph.Add("Echo", echo)
+	defer graph.Close()
+	defer close(echo.stop)
+
+	// without a delay, each message handled by echo
+	// blocks it from accepting the next one
+	graph.Connect("Source.Value", "Echo.Value")
+	graph.Connect("Echo.Next", "Echo.Value")
+	source.OutValue <- 0
+
+	var report *StallReport
+	select {
+	case report = <-reports:
+	case <-time.After(time.Second):
+		t.Fatal("expected stall to be reported")
+	}
+
+	if !IsStalled(report) {
+		t.Error("expected report to be a stall error")
+	}
+	if expected := []string{"Echo", "Echo"}; !reflect.DeepEqual(report.Cycle, expected) {
+		t.Errorf("expected cycle %v, got %v", expected, report.Cycle)
+	}
+	found := false
+	for _, blocked := range report.Blocked {
+		found = found || blocked.Port == "Echo.Next" && blocked.WaitingOn == "Echo.Value"
+	}
+	if !found {
+		t.Errorf("expected Echo.Next to be blocked on Echo.Value, got %+v", report.Blocked)
+	}
+	if report.Stacks == "" {
+		t.Error("expected report to include goroutine stacks")
+	}
+
+}
+
+func TestGraph_Watchdog_ErrorHandler(t *testing.T) {
+
+	errs := make(chan error, 10)
+	graph := NewGraph(
+		Watchdog(20*time.Millisecond, nil),
+		ErrorHandler(func(err error) { errs <- err }),
+	)
+	source := new(IntNode)
+	block := &blockNode{stop: make(chan struct{})}
+	graph.Add("Source", source)
+	graph.Add("Block", block)
+	defer graph.Close()
+	defer close(block.stop)
+
+	graph.Connect("Source.Value", "Block.Value")
+	source.OutValue <- 0
+
+	select {
+	case err := <-errs:
+		if !IsStalled(err) {
+			t.Errorf("expected stall error, got %v", err)
+		}
+		report := err.(*StallReport)
+		if len(report.Cycle) != 0 {
+			t.Errorf("expected no cycle, got %v", report.Cycle)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected stall to be reported to the error handler")
+	}
+
+}
+
+func TestGraph_Watchdog_SubGraph(t *testing.T) {
+
+	reports := make(chan *StallReport, 10)
+	subReports := make(chan *StallReport, 10)
+	graph := NewGraph(Watchdog(20*time.Millisecond, func(r *StallReport) { reports <- r }))
+	sub := NewGraph(Watchdog(time.Nanosecond, func(r *StallReport) { subReports <- r }))
+	source := new(IntNode)
+	block := &blockNode{stop: make(chan struct{})}
+	graph.Add("Sub", sub)
+	sub.Add("Source", source)
+	sub.Add("Block", block)
+	defer graph.Close()
+	defer close(block.stop)
+
+	sub.Connect("Source.Value", "Block.Value")
+	source.OutValue <- 0
+
+	select {
+	case <-reports:
+	case <-time.After(time.Second):
+		t.Fatal("expected stall to be reported by the outer graph")
+	}
+	select {
+	case r := <-subReports:
+		t.Errorf("expected only the outer graph to report, got %v", r)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+}